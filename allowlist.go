@@ -0,0 +1,233 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/security/groups"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/security/rules"
+	"github.com/sirupsen/logrus"
+)
+
+// defaultExpiryReminderDays is the number of days before expiry rules are warned of by default.
+const defaultExpiryReminderDays = 14
+
+// allowlistReport lists entries of Config.Rules that no longer have any effect.
+type allowlistReport struct {
+	// UnresolvedTenants are rules whose tenant is not found in the projects.
+	UnresolvedTenants []string
+	// Unmatched are rules whose security group or load balancer doesn't exist.
+	Unmatched []string
+	// ClosedPorts are allowed ports that are not opened by any rule of the security group.
+	ClosedPorts []string
+	// Expired are rules past their expiry date, which no longer suppress findings.
+	Expired []string
+	// Expiring are rules expiring within expiryReminderDays.
+	Expiring []string
+	// Unused are rules with ports that didn't suppress any finding in the last check.
+	Unused []string
+}
+
+// activeRules returns the allow rules that are not expired.
+func (checker *OpenStackSecurityGroupChecker) activeRules() []Rule {
+	now := time.Now()
+	rules := []Rule{}
+	for i, r := range checker.Cfg.Rules {
+		if !r.expired(now) {
+			r.index = i
+			rules = append(rules, r)
+		}
+	}
+	return rules
+}
+
+// markSuppressing records the allow rules that allow some of the traffic of the rule, which is
+// covered by them and therefore not reported.
+func (checker *OpenStackSecurityGroupChecker) markSuppressing(allowdRules []Rule, rule rules.SecGroupRule) {
+	if checker.suppressing == nil {
+		return
+	}
+	for _, r := range allowdRules {
+		for _, entry := range r.Port {
+			if allowdPortOverlaps(entry, rule) {
+				checker.suppressing[r.index] = true
+			}
+		}
+	}
+}
+
+// isUnused returns true if the rule didn't suppress any finding in the last check. Rules without
+// ports only change thresholds, and rules of disabled checks are never used.
+func (checker *OpenStackSecurityGroupChecker) isUnused(r Rule, index int) bool {
+	if checker.suppressed == nil || checker.suppressed[index] {
+		return false
+	}
+	if r.LoadBalancer != "" {
+		return checker.Cfg.LoadBalancerCheck && len(r.Port) > 0
+	}
+	if r.direction() == "egress" {
+		return checker.Cfg.Egress.Enabled && (len(r.Port) > 0 || len(r.Destinations) > 0)
+	}
+	return len(r.Port) > 0
+}
+
+func describeExpiry(r Rule) string {
+	s := fmt.Sprintf("%s, expires: %s, owner: %s", describeAllowdRule(r), r.Expires, r.Owner)
+	if r.Ticket != "" {
+		s += fmt.Sprintf(", ticket: %s", r.Ticket)
+	}
+	return s
+}
+
+func describeAllowdRule(r Rule) string {
+	target := r.SG
+	if r.LoadBalancer != "" {
+		target = "lb:" + r.LoadBalancer
+	}
+	s := fmt.Sprintf("%s / %s", r.Tenant, target)
+	if r.Source != "" {
+		s += fmt.Sprintf(" (%s)", r.Source)
+	}
+	return s
+}
+
+// expiryReminderDays returns the number of days before expiry rules are warned of.
+func (checker *OpenStackSecurityGroupChecker) expiryReminderDays() int {
+	if checker.Cfg.ExpiryReminderDays > 0 {
+		return checker.Cfg.ExpiryReminderDays
+	}
+	return defaultExpiryReminderDays
+}
+
+// allowlistHygiene finds allow rules that don't match any tenant, security group, load balancer
+// or open port.
+func (checker *OpenStackSecurityGroupChecker) allowlistHygiene(inv *inventory) allowlistReport {
+	report := allowlistReport{}
+	now := time.Now()
+	for i, r := range checker.Cfg.Rules {
+		if r.expired(now) {
+			report.Expired = append(report.Expired, describeExpiry(r))
+			continue
+		}
+		if r.expired(now.AddDate(0, 0, checker.expiryReminderDays())) {
+			report.Expiring = append(report.Expiring, describeExpiry(r))
+		}
+
+		if r.TenantID == "" && r.Tenant != "" {
+			report.UnresolvedTenants = append(report.UnresolvedTenants, describeAllowdRule(r))
+			continue
+		}
+
+		if r.LoadBalancer != "" {
+			found := false
+			for _, lb := range inv.LoadBalancers {
+				if checker.matchProject(r, lb.ProjectID) && (matchName(r.LoadBalancer, lb.Name) || lb.ID == r.LoadBalancer) {
+					found = true
+				}
+			}
+			if checker.Cfg.LoadBalancerCheck && !found {
+				report.Unmatched = append(report.Unmatched, describeAllowdRule(r))
+			} else if checker.isUnused(r, i) {
+				report.Unused = append(report.Unused, describeAllowdRule(r))
+			}
+			continue
+		}
+
+		sgs := []groups.SecGroup{}
+		for _, sg := range inv.SecurityGroups {
+			if checker.matchGroup(r, sg) {
+				sgs = append(sgs, sg)
+			}
+		}
+		if len(sgs) == 0 {
+			report.Unmatched = append(report.Unmatched, describeAllowdRule(r))
+			continue
+		}
+
+		closed := false
+		for _, entry := range r.Port {
+			if !allowdPortIsOpen(r, entry, sgs) {
+				report.ClosedPorts = append(report.ClosedPorts, fmt.Sprintf("%s: %s", describeAllowdRule(r), entry))
+				closed = true
+			}
+		}
+		if !closed && checker.isUnused(r, i) {
+			report.Unused = append(report.Unused, describeAllowdRule(r))
+		}
+	}
+	return report
+}
+
+// allowdPortIsOpen returns true if a rule of the security groups opens a port of the entry.
+func allowdPortIsOpen(r Rule, entry string, sgs []groups.SecGroup) bool {
+	for _, sg := range sgs {
+		for _, rule := range sg.Rules {
+			if rule.Direction == r.direction() && allowdPortOverlaps(entry, rule) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// allowdPortOverlaps returns true if the entry allows some of the ports of the rule.
+func allowdPortOverlaps(entry string, rule rules.SecGroupRule) bool {
+	protocol, ports := parseAllowedPort(entry)
+	if normalizeProtocol(rule.Protocol) != protocol {
+		return false
+	}
+	if ports == "" || !protocolHasPorts(protocol) {
+		return true
+	}
+	allowd, err := parsePortRanges(ports)
+	if err != nil {
+		return false
+	}
+	open := rulePortRange(rule)
+	uncovered := allowd.uncovered(open)
+	return len(uncovered) != 1 || uncovered[0] != open
+}
+
+func (checker *OpenStackSecurityGroupChecker) logAllowlistReport(report allowlistReport) {
+	for _, r := range report.UnresolvedTenants {
+		logrus.Warnf("Tenant of allow rule is not found: %s", r)
+	}
+	for _, r := range report.Unmatched {
+		logrus.Warnf("Allow rule doesn't match any security group or load balancer: %s", r)
+	}
+	for _, r := range report.ClosedPorts {
+		logrus.Warnf("Allowed port is no longer open: %s", r)
+	}
+	for _, r := range report.Expired {
+		logrus.Warnf("Allow rule is expired: %s", r)
+	}
+	for _, r := range report.Expiring {
+		logrus.Warnf("Allow rule expires soon: %s", r)
+	}
+	for _, r := range report.Unused {
+		logrus.Warnf("Allow rule didn't suppress any finding: %s", r)
+	}
+}
+
+func allowlistSections(report allowlistReport) []Section {
+	sections := []Section{}
+	if len(report.UnresolvedTenants) > 0 {
+		sections = append(sections, hygieneSection("Allow rules with unknown tenant", report.UnresolvedTenants))
+	}
+	if len(report.Unmatched) > 0 {
+		sections = append(sections, hygieneSection("Allow rules matching no security group", report.Unmatched))
+	}
+	if len(report.ClosedPorts) > 0 {
+		sections = append(sections, hygieneSection("Allowed ports that are no longer open", report.ClosedPorts))
+	}
+	if len(report.Expired) > 0 {
+		sections = append(sections, hygieneSection("Expired allow rules", report.Expired))
+	}
+	if len(report.Expiring) > 0 {
+		sections = append(sections, hygieneSection("Allow rules expiring soon, please renew or remove them", report.Expiring))
+	}
+	if len(report.Unused) > 0 {
+		sections = append(sections, hygieneSection("Allow rules suppressing no finding", report.Unused))
+	}
+	return sections
+}
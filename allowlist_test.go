@@ -0,0 +1,105 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gophercloud/gophercloud/openstack/identity/v3/projects"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/layer3/floatingips"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/security/groups"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/security/rules"
+)
+
+func TestAllowlistHygieneUnused(t *testing.T) {
+	inv := &inventory{
+		SecurityGroups: []groups.SecGroup{
+			{ID: "sg-web", Name: "web", TenantID: "p1", Rules: []rules.SecGroupRule{
+				ingress("ssh", "IPv4", "tcp", 22, 22, "0.0.0.0/0"),
+				ingress("http", "IPv4", "tcp", 80, 80, "0.0.0.0/0"),
+			}},
+			{ID: "sg-internal", Name: "internal", TenantID: "p1", Rules: []rules.SecGroupRule{
+				ingress("https", "IPv4", "tcp", 443, 443, "0.0.0.0/0"),
+			}},
+		},
+		Ports: []neutronPort{
+			testPort("port-web", "10.0.0.1", "sg-web"),
+			testPort("port-internal", "10.0.0.2", "sg-internal"),
+		},
+		FloatingIPs: []floatingips.FloatingIP{{FloatingIP: "203.0.113.10", PortID: "port-web"}},
+	}
+	checker := newTestChecker()
+	checker.Projects = []projects.Project{{ID: "p1", Name: "web"}}
+	checker.Cfg.Rules = []Rule{
+		// Suppresses the finding of ssh.
+		{Tenant: "web", TenantID: "p1", SG: "web", Port: []string{"22"}},
+		// Allows http only from a source that doesn't contain the world, which is still reported.
+		{Tenant: "web", TenantID: "p1", SG: "web", Port: []string{"80"}, Sources: []string{"198.51.100.0/24"}},
+		// The group is open, but it has no public port and no finding.
+		{Tenant: "web", TenantID: "p1", SG: "internal", Port: []string{"443"}},
+		// The port is closed, which is reported as such.
+		{Tenant: "web", TenantID: "p1", SG: "internal", Port: []string{"8443"}},
+		// Rules without ports only change thresholds.
+		{Tenant: "web", TenantID: "p1", SG: "web", MaxPublicPrefixSize: 8},
+	}
+
+	if report := checker.allowlistHygiene(inv); len(report.Unused) > 0 {
+		t.Errorf("unused rules are reported before a check: %v", report.Unused)
+	}
+
+	checker.suppressing = map[int]bool{}
+	for _, sg := range inv.SecurityGroups {
+		if _, err := checker.isFullOpen(sg, inv.Ports, inv.FloatingIPs, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	checker.suppressed, checker.suppressing = checker.suppressing, nil
+
+	report := checker.allowlistHygiene(inv)
+	if want := []string{"web / web", "web / internal"}; !reflect.DeepEqual(report.Unused, want) {
+		t.Errorf("Unused = %v, want %v", report.Unused, want)
+	}
+	if want := []string{"web / internal: 8443"}; !reflect.DeepEqual(report.ClosedPorts, want) {
+		t.Errorf("ClosedPorts = %v, want %v", report.ClosedPorts, want)
+	}
+}
+
+func TestAllowlistHygieneExpiring(t *testing.T) {
+	now := time.Now()
+	expires := func(days int) string {
+		return now.AddDate(0, 0, days).Format(time.RFC3339)
+	}
+	checker := newTestChecker()
+	checker.Cfg.Rules = []Rule{
+		{Tenant: "web", TenantID: "p1", SG: "expired", Port: []string{"22"}, Expires: expires(-1)},
+		{Tenant: "web", TenantID: "p1", SG: "soon", Port: []string{"22"}, Expires: expires(7)},
+		{Tenant: "web", TenantID: "p1", SG: "later", Port: []string{"22"}, Expires: expires(20)},
+		{Tenant: "web", TenantID: "p1", SG: "never", Port: []string{"22"}},
+	}
+	names := func(described []string) []string {
+		results := []string{}
+		for _, d := range described {
+			results = append(results, d[len("web / "):strings.Index(d, ",")])
+		}
+		return results
+	}
+
+	tests := []struct {
+		days int
+		want []string
+	}{
+		{0, []string{"soon"}},
+		{30, []string{"soon", "later"}},
+	}
+	for _, tt := range tests {
+		checker.Cfg.ExpiryReminderDays = tt.days
+		report := checker.allowlistHygiene(&inventory{})
+		if got := names(report.Expiring); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("expiry_reminder_days = %d: Expiring = %v, want %v", tt.days, got, tt.want)
+		}
+		if got := names(report.Expired); !reflect.DeepEqual(got, []string{"expired"}) {
+			t.Errorf("expiry_reminder_days = %d: Expired = %v, want [expired]", tt.days, got)
+		}
+	}
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/servers"
+	"github.com/gophercloud/gophercloud/openstack/identity/v3/projects"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/layer3/floatingips"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/security/groups"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/security/rules"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/networks"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/ports"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// awsProvider fetches EC2 security groups and network interfaces. Accounts become projects, VPCs
+// networks, network interfaces ports, their public IPs floating IPs and instances servers.
+type awsProvider struct {
+	Cfg AWS
+}
+
+func (p *awsProvider) Fetch() (*inventory, error) {
+	config := aws.NewConfig()
+	if p.Cfg.Region != "" {
+		config = config.WithRegion(p.Cfg.Region)
+	}
+	if p.Cfg.Endpoint != "" {
+		config = config.WithEndpoint(p.Cfg.Endpoint)
+	}
+	sess, err := session.NewSessionWithOptions(session.Options{Config: *config, SharedConfigState: session.SharedConfigEnable})
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to create AWS session")
+	}
+	client := ec2.New(sess)
+	inv := &inventory{}
+
+	err = client.DescribeSecurityGroupsPages(&ec2.DescribeSecurityGroupsInput{}, func(page *ec2.DescribeSecurityGroupsOutput, lastPage bool) bool {
+		for _, sg := range page.SecurityGroups {
+			inv.SecurityGroups = append(inv.SecurityGroups, ec2SecurityGroup(sg))
+		}
+		return true
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to security groups")
+	}
+
+	err = client.DescribeNetworkInterfacesPages(&ec2.DescribeNetworkInterfacesInput{}, func(page *ec2.DescribeNetworkInterfacesOutput, lastPage bool) bool {
+		for _, eni := range page.NetworkInterfaces {
+			port, fips := ec2NetworkInterface(eni)
+			inv.Ports = append(inv.Ports, port)
+			inv.FloatingIPs = append(inv.FloatingIPs, fips...)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to fetch network interfaces")
+	}
+
+	err = client.DescribeVpcsPages(&ec2.DescribeVpcsInput{}, func(page *ec2.DescribeVpcsOutput, lastPage bool) bool {
+		for _, vpc := range page.Vpcs {
+			inv.Networks = append(inv.Networks, networks.Network{
+				ID:       aws.StringValue(vpc.VpcId),
+				Name:     ec2TagValue(vpc.Tags, "Name"),
+				TenantID: aws.StringValue(vpc.OwnerId),
+			})
+		}
+		return true
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to fetch VPCs")
+	}
+
+	err = client.DescribeInstancesPages(&ec2.DescribeInstancesInput{}, func(page *ec2.DescribeInstancesOutput, lastPage bool) bool {
+		for _, reservation := range page.Reservations {
+			for _, instance := range reservation.Instances {
+				inv.Servers = append(inv.Servers, ec2Instance(reservation, instance))
+			}
+		}
+		return true
+	})
+	if err != nil {
+		logrus.Warnf("Failed to fetch instances, findings are not enriched with instances: %s", err)
+	}
+
+	inv.Projects = p.accounts(inv)
+	return inv, nil
+}
+
+// accounts returns the owners of the security groups and ports as projects, named by the
+// accounts config or by their ID.
+func (p *awsProvider) accounts(inv *inventory) []projects.Project {
+	ids := []string{}
+	for _, sg := range inv.SecurityGroups {
+		if !contain(ids, sg.TenantID) {
+			ids = append(ids, sg.TenantID)
+		}
+	}
+	for _, port := range inv.Ports {
+		if !contain(ids, port.TenantID) {
+			ids = append(ids, port.TenantID)
+		}
+	}
+	sort.Strings(ids)
+
+	results := []projects.Project{}
+	for _, id := range ids {
+		name, ok := p.Cfg.Accounts[id]
+		if !ok {
+			name = id
+		}
+		results = append(results, projects.Project{ID: id, Name: name, Enabled: true})
+	}
+	return results
+}
+
+func ec2SecurityGroup(sg *ec2.SecurityGroup) groups.SecGroup {
+	group := groups.SecGroup{
+		ID:          aws.StringValue(sg.GroupId),
+		Name:        aws.StringValue(sg.GroupName),
+		Description: aws.StringValue(sg.Description),
+		TenantID:    aws.StringValue(sg.OwnerId),
+		ProjectID:   aws.StringValue(sg.OwnerId),
+		Rules:       []rules.SecGroupRule{},
+		Tags:        []string{},
+	}
+	for _, tag := range sg.Tags {
+		group.Tags = append(group.Tags, fmt.Sprintf("%s=%s", aws.StringValue(tag.Key), aws.StringValue(tag.Value)))
+	}
+	group.Rules = append(group.Rules, ec2Rules(group, "ingress", sg.IpPermissions)...)
+	group.Rules = append(group.Rules, ec2Rules(group, "egress", sg.IpPermissionsEgress)...)
+	return group
+}
+
+// ec2Rules expands IP permissions into a rule per remote CIDR or group, as Neutron stores them.
+// Prefix lists are skipped since their entries are not resolved.
+func ec2Rules(sg groups.SecGroup, direction string, permissions []*ec2.IpPermission) []rules.SecGroupRule {
+	results := []rules.SecGroupRule{}
+	for _, permission := range permissions {
+		base := rules.SecGroupRule{
+			Direction:  direction,
+			EtherType:  "IPv4",
+			SecGroupID: sg.ID,
+			Protocol:   ec2Protocol(aws.StringValue(permission.IpProtocol)),
+			TenantID:   sg.TenantID,
+			ProjectID:  sg.ProjectID,
+		}
+		from, to := int(aws.Int64Value(permission.FromPort)), int(aws.Int64Value(permission.ToPort))
+		switch protocol := normalizeProtocol(base.Protocol); {
+		case protocol == "icmp" || protocol == "icmpv6":
+			// The ports are the ICMP type and code, where -1 means any. Any type is null in
+			// Neutron, but any code is kept as -1 since 0 is a valid type and code.
+			if from >= 0 {
+				base.PortRangeMin, base.PortRangeMax = from, to
+			}
+		default:
+			// -1 means all ports.
+			if from > 0 {
+				base.PortRangeMin = from
+			}
+			if to > 0 {
+				base.PortRangeMax = to
+			}
+		}
+
+		for _, r := range permission.IpRanges {
+			rule := base
+			rule.RemoteIPPrefix = aws.StringValue(r.CidrIp)
+			rule.Description = aws.StringValue(r.Description)
+			results = append(results, rule)
+		}
+		for _, r := range permission.Ipv6Ranges {
+			rule := base
+			rule.EtherType = "IPv6"
+			rule.RemoteIPPrefix = aws.StringValue(r.CidrIpv6)
+			rule.Description = aws.StringValue(r.Description)
+			results = append(results, rule)
+		}
+		for _, pair := range permission.UserIdGroupPairs {
+			rule := base
+			rule.RemoteGroupID = aws.StringValue(pair.GroupId)
+			rule.Description = aws.StringValue(pair.Description)
+			results = append(results, rule)
+		}
+	}
+	for i := range results {
+		results[i].ID = fmt.Sprintf("%s-%s-%d", sg.ID, direction, i)
+	}
+	return results
+}
+
+// ec2Protocol converts an EC2 protocol to the Neutron one, where any protocol is empty.
+func ec2Protocol(protocol string) string {
+	if protocol == "-1" {
+		return ""
+	}
+	return strings.ToLower(protocol)
+}
+
+// ec2NetworkInterface converts a network interface to a port and its public IPs to floating IPs.
+func ec2NetworkInterface(eni *ec2.NetworkInterface) (neutronPort, []floatingips.FloatingIP) {
+	portSecurityEnabled := true
+	port := neutronPort{
+		Port: ports.Port{
+			ID:             aws.StringValue(eni.NetworkInterfaceId),
+			NetworkID:      aws.StringValue(eni.VpcId),
+			Name:           ec2TagValue(eni.TagSet, "Name"),
+			Description:    aws.StringValue(eni.Description),
+			AdminStateUp:   true,
+			Status:         aws.StringValue(eni.Status),
+			MACAddress:     aws.StringValue(eni.MacAddress),
+			FixedIPs:       []ports.IP{},
+			TenantID:       aws.StringValue(eni.OwnerId),
+			ProjectID:      aws.StringValue(eni.OwnerId),
+			DeviceOwner:    aws.StringValue(eni.InterfaceType),
+			SecurityGroups: []string{},
+		},
+		PortSecurityEnabled: &portSecurityEnabled,
+	}
+	if eni.Attachment != nil {
+		port.DeviceID = aws.StringValue(eni.Attachment.InstanceId)
+	}
+	for _, group := range eni.Groups {
+		port.SecurityGroups = append(port.SecurityGroups, aws.StringValue(group.GroupId))
+	}
+
+	fips := []floatingips.FloatingIP{}
+	for _, address := range eni.PrivateIpAddresses {
+		privateIP := aws.StringValue(address.PrivateIpAddress)
+		port.FixedIPs = append(port.FixedIPs, ports.IP{SubnetID: aws.StringValue(eni.SubnetId), IPAddress: privateIP})
+		if address.Association == nil || aws.StringValue(address.Association.PublicIp) == "" {
+			continue
+		}
+		id := aws.StringValue(address.Association.AllocationId)
+		if id == "" {
+			id = aws.StringValue(address.Association.PublicIp)
+		}
+		fips = append(fips, floatingips.FloatingIP{
+			ID:         id,
+			FloatingIP: aws.StringValue(address.Association.PublicIp),
+			FixedIP:    privateIP,
+			PortID:     port.ID,
+			TenantID:   port.TenantID,
+			ProjectID:  port.ProjectID,
+			Status:     "ACTIVE",
+		})
+	}
+	for _, address := range eni.Ipv6Addresses {
+		port.FixedIPs = append(port.FixedIPs, ports.IP{SubnetID: aws.StringValue(eni.SubnetId), IPAddress: aws.StringValue(address.Ipv6Address)})
+	}
+	return port, fips
+}
+
+// ec2Instance converts an instance to a server whose metadata are the tags of the instance.
+func ec2Instance(reservation *ec2.Reservation, instance *ec2.Instance) servers.Server {
+	server := servers.Server{
+		ID:       aws.StringValue(instance.InstanceId),
+		TenantID: aws.StringValue(reservation.OwnerId),
+		Name:     ec2TagValue(instance.Tags, "Name"),
+		Metadata: map[string]string{},
+	}
+	if instance.State != nil {
+		server.Status = aws.StringValue(instance.State.Name)
+	}
+	if instance.LaunchTime != nil {
+		server.Created = *instance.LaunchTime
+	}
+	for _, tag := range instance.Tags {
+		server.Metadata[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+	}
+	return server
+}
+
+func ec2TagValue(tags []*ec2.Tag, key string) string {
+	for _, tag := range tags {
+		if aws.StringValue(tag.Key) == key {
+			return aws.StringValue(tag.Value)
+		}
+	}
+	return ""
+}
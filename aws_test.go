@@ -0,0 +1,371 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/layer3/floatingips"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/security/groups"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/security/rules"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/ports"
+)
+
+// fakeEC2Responses are the bodies of the EC2 query API actions used by awsProvider.
+var fakeEC2Responses = map[string]string{
+	"DescribeSecurityGroups": `<DescribeSecurityGroupsResponse xmlns="http://ec2.amazonaws.com/doc/2016-11-15/">
+  <requestId>1</requestId>
+  <securityGroupInfo>
+    <item>
+      <ownerId>111122223333</ownerId>
+      <groupId>sg-web</groupId>
+      <groupName>web</groupName>
+      <groupDescription>web servers</groupDescription>
+      <vpcId>vpc-1</vpcId>
+      <ipPermissions>
+        <item>
+          <ipProtocol>tcp</ipProtocol>
+          <fromPort>443</fromPort>
+          <toPort>443</toPort>
+          <groups>
+            <item><userId>111122223333</userId><groupId>sg-lb</groupId><description>from lb</description></item>
+          </groups>
+          <ipRanges>
+            <item><cidrIp>0.0.0.0/0</cidrIp><description>https</description></item>
+          </ipRanges>
+          <ipv6Ranges>
+            <item><cidrIpv6>::/0</cidrIpv6></item>
+          </ipv6Ranges>
+          <prefixListIds/>
+        </item>
+        <item>
+          <ipProtocol>tcp</ipProtocol>
+          <fromPort>22</fromPort>
+          <toPort>22</toPort>
+          <groups/>
+          <ipRanges/>
+          <ipv6Ranges/>
+          <prefixListIds>
+            <item><prefixListId>pl-1234</prefixListId></item>
+          </prefixListIds>
+        </item>
+        <item>
+          <ipProtocol>icmp</ipProtocol>
+          <fromPort>8</fromPort>
+          <toPort>-1</toPort>
+          <groups/>
+          <ipRanges>
+            <item><cidrIp>10.0.0.0/8</cidrIp></item>
+          </ipRanges>
+          <ipv6Ranges/>
+          <prefixListIds/>
+        </item>
+        <item>
+          <ipProtocol>icmp</ipProtocol>
+          <fromPort>3</fromPort>
+          <toPort>4</toPort>
+          <groups/>
+          <ipRanges>
+            <item><cidrIp>10.0.0.0/8</cidrIp></item>
+          </ipRanges>
+          <ipv6Ranges/>
+          <prefixListIds/>
+        </item>
+        <item>
+          <ipProtocol>icmp</ipProtocol>
+          <fromPort>0</fromPort>
+          <toPort>-1</toPort>
+          <groups/>
+          <ipRanges>
+            <item><cidrIp>10.0.0.0/8</cidrIp></item>
+          </ipRanges>
+          <ipv6Ranges/>
+          <prefixListIds/>
+        </item>
+        <item>
+          <ipProtocol>icmpv6</ipProtocol>
+          <fromPort>-1</fromPort>
+          <toPort>-1</toPort>
+          <groups/>
+          <ipRanges/>
+          <ipv6Ranges>
+            <item><cidrIpv6>fd00::/8</cidrIpv6></item>
+          </ipv6Ranges>
+          <prefixListIds/>
+        </item>
+      </ipPermissions>
+      <ipPermissionsEgress>
+        <item>
+          <ipProtocol>-1</ipProtocol>
+          <groups/>
+          <ipRanges>
+            <item><cidrIp>0.0.0.0/0</cidrIp></item>
+          </ipRanges>
+          <ipv6Ranges/>
+          <prefixListIds/>
+        </item>
+      </ipPermissionsEgress>
+      <tagSet>
+        <item><key>env</key><value>prod</value></item>
+      </tagSet>
+    </item>
+  </securityGroupInfo>
+</DescribeSecurityGroupsResponse>`,
+	"DescribeNetworkInterfaces": `<DescribeNetworkInterfacesResponse xmlns="http://ec2.amazonaws.com/doc/2016-11-15/">
+  <requestId>2</requestId>
+  <networkInterfaceSet>
+    <item>
+      <networkInterfaceId>eni-1</networkInterfaceId>
+      <subnetId>subnet-1</subnetId>
+      <vpcId>vpc-1</vpcId>
+      <description>web eth0</description>
+      <ownerId>111122223333</ownerId>
+      <status>in-use</status>
+      <macAddress>02:00:00:00:00:01</macAddress>
+      <interfaceType>interface</interfaceType>
+      <groupSet>
+        <item><groupId>sg-web</groupId><groupName>web</groupName></item>
+      </groupSet>
+      <attachment><instanceId>i-1</instanceId></attachment>
+      <privateIpAddressesSet>
+        <item>
+          <privateIpAddress>10.0.0.5</privateIpAddress>
+          <primary>true</primary>
+          <association><publicIp>203.0.113.5</publicIp><allocationId>eipalloc-1</allocationId></association>
+        </item>
+        <item>
+          <privateIpAddress>10.0.0.6</privateIpAddress>
+          <primary>false</primary>
+          <association><publicIp>203.0.113.6</publicIp></association>
+        </item>
+        <item>
+          <privateIpAddress>10.0.0.7</privateIpAddress>
+          <primary>false</primary>
+        </item>
+      </privateIpAddressesSet>
+      <ipv6AddressesSet>
+        <item><ipv6Address>2001:db8::5</ipv6Address></item>
+      </ipv6AddressesSet>
+      <tagSet>
+        <item><key>Name</key><value>web-eth0</value></item>
+      </tagSet>
+    </item>
+  </networkInterfaceSet>
+</DescribeNetworkInterfacesResponse>`,
+	"DescribeVpcs": `<DescribeVpcsResponse xmlns="http://ec2.amazonaws.com/doc/2016-11-15/">
+  <requestId>3</requestId>
+  <vpcSet>
+    <item>
+      <vpcId>vpc-1</vpcId>
+      <ownerId>111122223333</ownerId>
+      <tagSet>
+        <item><key>Name</key><value>main</value></item>
+      </tagSet>
+    </item>
+  </vpcSet>
+</DescribeVpcsResponse>`,
+	"DescribeInstances": `<DescribeInstancesResponse xmlns="http://ec2.amazonaws.com/doc/2016-11-15/">
+  <requestId>4</requestId>
+  <reservationSet>
+    <item>
+      <reservationId>r-1</reservationId>
+      <ownerId>111122223333</ownerId>
+      <instancesSet>
+        <item>
+          <instanceId>i-1</instanceId>
+          <instanceState><code>16</code><name>running</name></instanceState>
+          <launchTime>2020-01-02T03:04:05.000Z</launchTime>
+          <tagSet>
+            <item><key>Name</key><value>web-1</value></item>
+            <item><key>owner</key><value>alice</value></item>
+          </tagSet>
+        </item>
+      </instancesSet>
+    </item>
+  </reservationSet>
+</DescribeInstancesResponse>`,
+}
+
+func newFakeEC2(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("Failed to parse request: %s", err)
+		}
+		body, ok := fakeEC2Responses[r.Form.Get("Action")]
+		if !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, `<Response><Errors><Error><Code>InvalidAction</Code><Message>%s</Message></Error></Errors><RequestID>0</RequestID></Response>`, r.Form.Get("Action"))
+			return
+		}
+		w.Header().Set("Content-Type", "text/xml")
+		fmt.Fprint(w, body)
+	}))
+}
+
+func TestAWSProviderFetch(t *testing.T) {
+	server := newFakeEC2(t)
+	defer server.Close()
+	setenv(t, "AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	setenv(t, "AWS_SECRET_ACCESS_KEY", "secret")
+	setenv(t, "AWS_CONFIG_FILE", os.DevNull)
+	setenv(t, "AWS_SHARED_CREDENTIALS_FILE", os.DevNull)
+
+	p := &awsProvider{Cfg: AWS{Region: "us-east-1", Endpoint: server.URL, Accounts: map[string]string{"111122223333": "prod"}}}
+	inv, err := p.Fetch()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(inv.Projects) != 1 || inv.Projects[0].ID != "111122223333" || inv.Projects[0].Name != "prod" {
+		t.Errorf("Projects = %+v, want the account 111122223333 named prod", inv.Projects)
+	}
+
+	if len(inv.SecurityGroups) != 1 {
+		t.Fatalf("got %d security groups, want 1", len(inv.SecurityGroups))
+	}
+	sg := inv.SecurityGroups[0]
+	if sg.ID != "sg-web" || sg.Name != "web" || sg.Description != "web servers" || sg.TenantID != "111122223333" {
+		t.Errorf("security group = %+v", sg)
+	}
+	if !reflect.DeepEqual(sg.Tags, []string{"env=prod"}) {
+		t.Errorf("Tags = %v, want [env=prod]", sg.Tags)
+	}
+	rule := func(id, direction, etherType, protocol string, min, max int, prefix, group, description string) rules.SecGroupRule {
+		return rules.SecGroupRule{
+			ID:             id,
+			Direction:      direction,
+			Description:    description,
+			EtherType:      etherType,
+			SecGroupID:     "sg-web",
+			PortRangeMin:   min,
+			PortRangeMax:   max,
+			Protocol:       protocol,
+			RemoteGroupID:  group,
+			RemoteIPPrefix: prefix,
+			TenantID:       "111122223333",
+			ProjectID:      "111122223333",
+		}
+	}
+	wantRules := []rules.SecGroupRule{
+		rule("sg-web-ingress-0", "ingress", "IPv4", "tcp", 443, 443, "0.0.0.0/0", "", "https"),
+		rule("sg-web-ingress-1", "ingress", "IPv6", "tcp", 443, 443, "::/0", "", ""),
+		rule("sg-web-ingress-2", "ingress", "IPv4", "tcp", 443, 443, "", "sg-lb", "from lb"),
+		// The prefix list permission on 22 is skipped. ICMP ports are the type and code, where
+		// any code is kept as -1 and any type is null.
+		rule("sg-web-ingress-3", "ingress", "IPv4", "icmp", 8, -1, "10.0.0.0/8", "", ""),
+		rule("sg-web-ingress-4", "ingress", "IPv4", "icmp", 3, 4, "10.0.0.0/8", "", ""),
+		rule("sg-web-ingress-5", "ingress", "IPv4", "icmp", 0, -1, "10.0.0.0/8", "", ""),
+		rule("sg-web-ingress-6", "ingress", "IPv6", "icmpv6", 0, 0, "fd00::/8", "", ""),
+		// -1 is any protocol.
+		rule("sg-web-egress-0", "egress", "IPv4", "", 0, 0, "0.0.0.0/0", "", ""),
+	}
+	if !reflect.DeepEqual(sg.Rules, wantRules) {
+		t.Errorf("Rules =\n%+v\nwant\n%+v", sg.Rules, wantRules)
+	}
+
+	if len(inv.Ports) != 1 {
+		t.Fatalf("got %d ports, want 1", len(inv.Ports))
+	}
+	port := inv.Ports[0]
+	if port.ID != "eni-1" || port.Name != "web-eth0" || port.NetworkID != "vpc-1" || port.DeviceID != "i-1" || port.TenantID != "111122223333" {
+		t.Errorf("port = %+v", port.Port)
+	}
+	if port.PortSecurityEnabled == nil || !*port.PortSecurityEnabled {
+		t.Error("port security of a network interface is not enabled")
+	}
+	if !reflect.DeepEqual(port.SecurityGroups, []string{"sg-web"}) {
+		t.Errorf("SecurityGroups = %v, want [sg-web]", port.SecurityGroups)
+	}
+	wantIPs := []ports.IP{
+		{SubnetID: "subnet-1", IPAddress: "10.0.0.5"},
+		{SubnetID: "subnet-1", IPAddress: "10.0.0.6"},
+		{SubnetID: "subnet-1", IPAddress: "10.0.0.7"},
+		{SubnetID: "subnet-1", IPAddress: "2001:db8::5"},
+	}
+	if !reflect.DeepEqual(port.FixedIPs, wantIPs) {
+		t.Errorf("FixedIPs = %+v, want %+v", port.FixedIPs, wantIPs)
+	}
+
+	wantFIPs := []floatingips.FloatingIP{
+		{ID: "eipalloc-1", FloatingIP: "203.0.113.5", FixedIP: "10.0.0.5", PortID: "eni-1", TenantID: "111122223333", ProjectID: "111122223333", Status: "ACTIVE"},
+		{ID: "203.0.113.6", FloatingIP: "203.0.113.6", FixedIP: "10.0.0.6", PortID: "eni-1", TenantID: "111122223333", ProjectID: "111122223333", Status: "ACTIVE"},
+	}
+	if !reflect.DeepEqual(inv.FloatingIPs, wantFIPs) {
+		t.Errorf("FloatingIPs = %+v, want %+v", inv.FloatingIPs, wantFIPs)
+	}
+
+	if len(inv.Networks) != 1 || inv.Networks[0].ID != "vpc-1" || inv.Networks[0].Name != "main" || inv.Networks[0].TenantID != "111122223333" {
+		t.Errorf("Networks = %+v, want vpc-1 named main", inv.Networks)
+	}
+
+	if len(inv.Servers) != 1 {
+		t.Fatalf("got %d servers, want 1", len(inv.Servers))
+	}
+	instance := inv.Servers[0]
+	if instance.ID != "i-1" || instance.Name != "web-1" || instance.Status != "running" || instance.TenantID != "111122223333" {
+		t.Errorf("server = %+v", instance)
+	}
+	if !instance.Created.Equal(time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)) {
+		t.Errorf("Created = %s, want 2020-01-02T03:04:05Z", instance.Created)
+	}
+	if !reflect.DeepEqual(instance.Metadata, map[string]string{"Name": "web-1", "owner": "alice"}) {
+		t.Errorf("Metadata = %v", instance.Metadata)
+	}
+}
+
+func TestEC2Protocol(t *testing.T) {
+	tests := []struct {
+		protocol string
+		want     string
+	}{
+		{"-1", ""},
+		{"tcp", "tcp"},
+		{"UDP", "udp"},
+		{"icmpv6", "icmpv6"},
+		{"50", "50"},
+	}
+	for _, tt := range tests {
+		if got := ec2Protocol(tt.protocol); got != tt.want {
+			t.Errorf("ec2Protocol(%q) = %q, want %q", tt.protocol, got, tt.want)
+		}
+	}
+}
+
+func TestEC2RulesICMP(t *testing.T) {
+	permission := func(protocol string, from, to int64) *ec2.IpPermission {
+		return &ec2.IpPermission{
+			IpProtocol: aws.String(protocol),
+			FromPort:   aws.Int64(from),
+			ToPort:     aws.Int64(to),
+			IpRanges:   []*ec2.IpRange{{CidrIp: aws.String("10.0.0.0/8")}},
+		}
+	}
+	tests := []struct {
+		permission *ec2.IpPermission
+		want       string
+	}{
+		{permission("icmp", -1, -1), "any"},
+		{permission("icmp", 8, -1), "type 8"},
+		{permission("icmp", 0, -1), "type 0"},
+		{permission("icmp", 3, 4), "type 3 code 4"},
+		{permission("icmpv6", 128, -1), "type 128"},
+		{permission("tcp", 22, 22), "22-22"},
+		{permission("-1", -1, -1), "any"},
+	}
+	for _, tt := range tests {
+		rs := ec2Rules(groups.SecGroup{ID: "sg"}, "ingress", []*ec2.IpPermission{tt.permission})
+		if len(rs) != 1 {
+			t.Fatalf("%s: rules = %+v", tt.want, rs)
+		}
+		if got := formatPortRange(rs[0]); got != tt.want {
+			t.Errorf("%s %d/%d: formatPortRange() = %q, want %q", aws.StringValue(tt.permission.IpProtocol),
+				aws.Int64Value(tt.permission.FromPort), aws.Int64Value(tt.permission.ToPort), got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,402 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/services/network/mgmt/2020-06-01/network"
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/Azure/go-autorest/autorest/azure/auth"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/gophercloud/gophercloud/openstack/identity/v3/projects"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/layer3/floatingips"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/security/groups"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/networks"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/ports"
+	"github.com/pkg/errors"
+)
+
+// azureProvider fetches network security groups and network interfaces. Subscriptions become
+// projects, virtual networks networks and public IPs of network interfaces floating IPs. Each allow
+// rule of a network security group becomes a security group attached to the interfaces the rule
+// applies to, among those the network security group is associated with directly or through their
+// subnet. Deny rules, including the default rules, are applied to the allow rules of lower priority.
+type azureProvider struct {
+	Cfg Azure
+}
+
+// azureInterface is a network interface, with the attributes security rules are scoped by.
+type azureInterface struct {
+	Port    neutronPort
+	NSG     string
+	Subnets []string
+	ASGs    []string
+}
+
+func (p *azureProvider) Fetch() (*inventory, error) {
+	ctx := context.Background()
+	baseURI := network.DefaultBaseURI
+	var authorizer autorest.Authorizer = autorest.NullAuthorizer{}
+	if p.Cfg.Endpoint != "" {
+		baseURI = p.Cfg.Endpoint
+	} else {
+		var err error
+		authorizer, err = auth.NewAuthorizerFromEnvironment()
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to authenticate Azure API")
+		}
+	}
+
+	inv := &inventory{}
+	for _, subscription := range p.Cfg.Subscriptions {
+		name, ok := p.Cfg.SubscriptionNames[subscription]
+		if !ok {
+			name = subscription
+		}
+		inv.Projects = append(inv.Projects, projects.Project{ID: subscription, Name: name, Enabled: true})
+
+		publicIPs := map[string]string{}
+		publicIPClient := network.NewPublicIPAddressesClientWithBaseURI(baseURI, subscription)
+		publicIPClient.Authorizer = authorizer
+		ips, err := publicIPClient.ListAllComplete(ctx)
+		for ; err == nil && ips.NotDone(); err = ips.NextWithContext(ctx) {
+			ip := ips.Value()
+			if ip.PublicIPAddressPropertiesFormat != nil {
+				publicIPs[strings.ToLower(to.String(ip.ID))] = to.String(ip.IPAddress)
+			}
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to fetch public IP addresses of %s", subscription)
+		}
+
+		interfaces := []azureInterface{}
+		interfaceClient := network.NewInterfacesClientWithBaseURI(baseURI, subscription)
+		interfaceClient.Authorizer = authorizer
+		nics, err := interfaceClient.ListAllComplete(ctx)
+		for ; err == nil && nics.NotDone(); err = nics.NextWithContext(ctx) {
+			i, fips := azureNetworkInterface(subscription, nics.Value(), publicIPs)
+			interfaces = append(interfaces, i)
+			inv.FloatingIPs = append(inv.FloatingIPs, fips...)
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to fetch network interfaces of %s", subscription)
+		}
+
+		securityGroupClient := network.NewSecurityGroupsClientWithBaseURI(baseURI, subscription)
+		securityGroupClient.Authorizer = authorizer
+		nsgs, err := securityGroupClient.ListAllComplete(ctx)
+		for ; err == nil && nsgs.NotDone(); err = nsgs.NextWithContext(ctx) {
+			nsg := nsgs.Value()
+			sgs, targets := azureSecurityGroups(subscription, nsg, azureAttachedInterfaces(nsg, interfaces))
+			inv.SecurityGroups = append(inv.SecurityGroups, sgs...)
+			for _, sg := range sgs {
+				for i := range interfaces {
+					if contain(targets[sg.ID], interfaces[i].Port.ID) {
+						interfaces[i].Port.SecurityGroups = append(interfaces[i].Port.SecurityGroups, sg.ID)
+					}
+				}
+			}
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to fetch network security groups of %s", subscription)
+		}
+
+		for _, i := range interfaces {
+			inv.Ports = append(inv.Ports, i.Port)
+			if i.Port.NetworkID != "" && !containNetwork(inv.Networks, i.Port.NetworkID) {
+				inv.Networks = append(inv.Networks, networks.Network{ID: i.Port.NetworkID, Name: lastPathSegment(i.Port.NetworkID), TenantID: subscription})
+			}
+		}
+	}
+	return inv, nil
+}
+
+func containNetwork(ns []networks.Network, id string) bool {
+	for _, n := range ns {
+		if n.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// azureAttachedInterfaces returns the interfaces the network security group is associated with
+// directly or through their subnet. Traffic must be allowed by both when both are associated, which
+// is not taken into account.
+func azureAttachedInterfaces(nsg network.SecurityGroup, interfaces []azureInterface) []azureInterface {
+	subnets := []string{}
+	if nsg.SecurityGroupPropertiesFormat != nil && nsg.Subnets != nil {
+		for _, subnet := range *nsg.Subnets {
+			subnets = append(subnets, strings.ToLower(to.String(subnet.ID)))
+		}
+	}
+	results := []azureInterface{}
+	for _, i := range interfaces {
+		attached := i.NSG == strings.ToLower(to.String(nsg.ID))
+		for _, subnet := range i.Subnets {
+			attached = attached || contain(subnets, subnet)
+		}
+		if attached {
+			results = append(results, i)
+		}
+	}
+	return results
+}
+
+// azureSecurityGroups converts each allow rule of the network security group to a security group,
+// like a GCP firewall rule. The returned map has IDs of the interfaces each security group applies
+// to, so that a rule scoped by addresses or application security groups doesn't reach the other
+// interfaces of the network security group.
+func azureSecurityGroups(subscription string, nsg network.SecurityGroup, attached []azureInterface) ([]groups.SecGroup, map[string][]string) {
+	type converted struct {
+		ID    string
+		Rule  network.SecurityRule
+		Rules []firewallRule
+	}
+	all := []firewallRule{}
+	convertedRules := []converted{}
+	if nsg.SecurityGroupPropertiesFormat != nil {
+		collections := []struct {
+			Name  string
+			Rules *[]network.SecurityRule
+		}{{"securityRules", nsg.SecurityRules}, {"defaultSecurityRules", nsg.DefaultSecurityRules}}
+		for _, collection := range collections {
+			if collection.Rules == nil {
+				continue
+			}
+			for _, rule := range *collection.Rules {
+				rules := azureFirewallRules(rule, attached)
+				all = append(all, rules...)
+				id := fmt.Sprintf("%s/%s/%s", to.String(nsg.ID), collection.Name, to.String(rule.Name))
+				convertedRules = append(convertedRules, converted{ID: id, Rule: rule, Rules: rules})
+			}
+		}
+	}
+
+	sgs := []groups.SecGroup{}
+	targets := map[string][]string{}
+	for _, c := range convertedRules {
+		if len(c.Rules) == 0 || c.Rules[0].Deny {
+			continue
+		}
+		sg := groups.SecGroup{
+			ID:          c.ID,
+			Name:        fmt.Sprintf("%s/%s", to.String(nsg.Name), to.String(c.Rule.Name)),
+			Description: to.String(c.Rule.Description),
+			TenantID:    subscription,
+			ProjectID:   subscription,
+			Tags: []string{
+				fmt.Sprintf("location=%s", to.String(nsg.Location)),
+				fmt.Sprintf("nsg=%s", to.String(nsg.Name)),
+				fmt.Sprintf("priority=%d", c.Rules[0].Priority),
+			},
+		}
+		for k, v := range nsg.Tags {
+			sg.Tags = append(sg.Tags, fmt.Sprintf("%s=%s", k, to.String(v)))
+		}
+		for _, rule := range c.Rules {
+			sg.Rules = append(sg.Rules, rule.allowedRules(all)...)
+		}
+		for i := range sg.Rules {
+			sg.Rules[i].ID = fmt.Sprintf("%s-%d", sg.ID, i)
+			sg.Rules[i].SecGroupID = sg.ID
+			sg.Rules[i].TenantID = subscription
+			sg.Rules[i].ProjectID = subscription
+		}
+		sgs = append(sgs, sg)
+
+		targets[sg.ID] = c.Rules[0].Targets
+		if c.Rules[0].AllTargets {
+			targets[sg.ID] = []string{}
+			for _, i := range attached {
+				targets[sg.ID] = append(targets[sg.ID], i.Port.ID)
+			}
+		}
+	}
+	return sgs, targets
+}
+
+// azureFirewallRules converts a security rule to firewall rules. A rule for any protocol with
+// ports is split into tcp and udp, since only they have ports.
+func azureFirewallRules(rule network.SecurityRule, attached []azureInterface) []firewallRule {
+	if rule.SecurityRulePropertiesFormat == nil {
+		return nil
+	}
+	base := firewallRule{
+		Priority:    int(to.Int32(rule.Priority)),
+		Deny:        rule.Access == network.SecurityRuleAccessDeny,
+		Direction:   "egress",
+		Description: to.String(rule.Name),
+	}
+	remotePrefixes := azurePrefixes(rule.DestinationAddressPrefix, rule.DestinationAddressPrefixes)
+	remoteASGs := rule.DestinationApplicationSecurityGroups
+	localPrefixes := azurePrefixes(rule.SourceAddressPrefix, rule.SourceAddressPrefixes)
+	localASGs := rule.SourceApplicationSecurityGroups
+	if rule.Direction == network.SecurityRuleDirectionInbound {
+		base.Direction = "ingress"
+		remotePrefixes, localPrefixes = localPrefixes, remotePrefixes
+		remoteASGs, localASGs = localASGs, remoteASGs
+	}
+
+	for _, prefix := range remotePrefixes {
+		switch strings.ToLower(prefix) {
+		case "*", "any", "internet":
+			base.Remotes = append(base.Remotes, "0.0.0.0/0", "::/0")
+			continue
+		}
+		if cidr, ok := firewallRemote(prefix); ok {
+			base.Remotes = append(base.Remotes, cidr)
+		} else {
+			// Service tags such as VirtualNetwork and AzureLoadBalancer.
+			base.RemoteGroups = append(base.RemoteGroups, "tag:"+prefix)
+		}
+	}
+	if remoteASGs != nil {
+		for _, asg := range *remoteASGs {
+			base.RemoteGroups = append(base.RemoteGroups, to.String(asg.ID))
+		}
+	}
+	base.AllTargets, base.Targets = azureTargets(localPrefixes, localASGs, attached)
+
+	ports := azurePrefixes(rule.DestinationPortRange, rule.DestinationPortRanges)
+	if !contain(ports, "*") && len(ports) > 0 {
+		ranges, err := parsePortRanges(strings.Join(ports, ","))
+		if err != nil {
+			return nil
+		}
+		base.Ports = ranges
+	}
+
+	protocol := firewallProtocol(string(rule.Protocol))
+	if protocol == "" && len(base.Ports) > 0 {
+		tcp, udp := base, base
+		tcp.Protocol, udp.Protocol = "tcp", "udp"
+		return []firewallRule{tcp, udp}
+	}
+	base.Protocol = protocol
+	return []firewallRule{base}
+}
+
+// azureTargets returns the interfaces matching the local side of a rule, i.e. the destination of
+// inbound rules and the source of outbound rules.
+func azureTargets(prefixes []string, asgs *[]network.ApplicationSecurityGroup, attached []azureInterface) (bool, []string) {
+	if asgs == nil || len(*asgs) == 0 {
+		for _, prefix := range prefixes {
+			switch strings.ToLower(prefix) {
+			case "*", "any", "virtualnetwork":
+				return true, nil
+			}
+		}
+	}
+	targets := []string{}
+	for _, i := range attached {
+		matched := false
+		if asgs != nil {
+			for _, asg := range *asgs {
+				matched = matched || contain(i.ASGs, strings.ToLower(to.String(asg.ID)))
+			}
+		}
+		for _, prefix := range prefixes {
+			cidr, ok := firewallRemote(prefix)
+			if !ok {
+				continue
+			}
+			_, network, err := net.ParseCIDR(cidr)
+			if err != nil {
+				continue
+			}
+			for _, ip := range i.Port.FixedIPs {
+				matched = matched || network.Contains(net.ParseIP(ip.IPAddress))
+			}
+		}
+		if matched {
+			targets = append(targets, i.Port.ID)
+		}
+	}
+	return false, targets
+}
+
+func azurePrefixes(prefix *string, prefixes *[]string) []string {
+	results := []string{}
+	if to.String(prefix) != "" {
+		results = append(results, to.String(prefix))
+	}
+	if prefixes != nil {
+		results = append(results, *prefixes...)
+	}
+	return results
+}
+
+// azureNetworkInterface converts a network interface to a port and its public IPs to floating IPs.
+func azureNetworkInterface(subscription string, nic network.Interface, publicIPs map[string]string) (azureInterface, []floatingips.FloatingIP) {
+	portSecurityEnabled := true
+	i := azureInterface{
+		Port: neutronPort{
+			Port: ports.Port{
+				ID:             to.String(nic.ID),
+				Name:           to.String(nic.Name),
+				AdminStateUp:   true,
+				FixedIPs:       []ports.IP{},
+				TenantID:       subscription,
+				ProjectID:      subscription,
+				SecurityGroups: []string{},
+			},
+			PortSecurityEnabled: &portSecurityEnabled,
+		},
+	}
+	fips := []floatingips.FloatingIP{}
+	if nic.InterfacePropertiesFormat == nil {
+		return i, fips
+	}
+	i.Port.MACAddress = to.String(nic.MacAddress)
+	if nic.VirtualMachine != nil {
+		i.Port.DeviceID = to.String(nic.VirtualMachine.ID)
+		i.Port.DeviceOwner = "compute"
+	}
+	if nic.NetworkSecurityGroup != nil {
+		i.NSG = strings.ToLower(to.String(nic.NetworkSecurityGroup.ID))
+	}
+	if nic.IPConfigurations == nil {
+		return i, fips
+	}
+	for _, config := range *nic.IPConfigurations {
+		if config.InterfaceIPConfigurationPropertiesFormat == nil {
+			continue
+		}
+		subnet := ""
+		if config.Subnet != nil {
+			subnet = to.String(config.Subnet.ID)
+			i.Subnets = append(i.Subnets, strings.ToLower(subnet))
+			// Subnet IDs are <virtual network ID>/subnets/<name>.
+			if n := strings.Index(strings.ToLower(subnet), "/subnets/"); n >= 0 && i.Port.NetworkID == "" {
+				i.Port.NetworkID = subnet[:n]
+			}
+		}
+		if config.ApplicationSecurityGroups != nil {
+			for _, asg := range *config.ApplicationSecurityGroups {
+				i.ASGs = append(i.ASGs, strings.ToLower(to.String(asg.ID)))
+			}
+		}
+		privateIP := to.String(config.PrivateIPAddress)
+		i.Port.FixedIPs = append(i.Port.FixedIPs, ports.IP{SubnetID: subnet, IPAddress: privateIP})
+		if config.PublicIPAddress == nil {
+			continue
+		}
+		publicIP := publicIPs[strings.ToLower(to.String(config.PublicIPAddress.ID))]
+		if publicIP == "" {
+			continue
+		}
+		fips = append(fips, floatingips.FloatingIP{
+			ID:         to.String(config.PublicIPAddress.ID),
+			FloatingIP: publicIP,
+			FixedIP:    privateIP,
+			PortID:     i.Port.ID,
+			TenantID:   subscription,
+			ProjectID:  subscription,
+			Status:     "ACTIVE",
+		})
+	}
+	return i, fips
+}
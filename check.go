@@ -18,7 +18,7 @@ func StartCheck(c *cli.Context) error {
 		slack.OptionDebug(true)(api)
 	}
 
-	if err := NewOpenStackChecker(cfg, api).Run(); err != nil {
+	if _, err := NewOpenStackChecker(cfg, api).Run(); err != nil {
 		return errors.Wrap(err, "Failed to check")
 	}
 
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"net"
+
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/security/groups"
+)
+
+// trustedCIDRSets returns the names of the CIDR sets trusted for the security group:
+// the global trusted_cidr_sets plus cidr_sets of the rules matching the group.
+func (checker *OpenStackSecurityGroupChecker) trustedCIDRSets(sg groups.SecGroup) []string {
+	names := []string{}
+	names = append(names, checker.Cfg.TrustedCIDRSets...)
+	for _, r := range checker.activeRules() {
+		if checker.matchGroup(r, sg) && r.direction() == "ingress" {
+			names = append(names, r.CIDRSets...)
+		}
+	}
+	return names
+}
+
+// isUntrustedSource returns true if the source includes public addresses and is not contained
+// in any CIDR set trusted for the security group. It also returns the name of a trusted set
+// that the source partially overlaps, if any. Sources are always trusted when no set applies.
+func (checker *OpenStackSecurityGroupChecker) isUntrustedSource(sg groups.SecGroup, source ruleSource) (bool, string, error) {
+	names := checker.trustedCIDRSets(sg)
+	if len(names) == 0 || source.RemoteGroupID != "" {
+		return false, "", nil
+	}
+	_, prefix, err := net.ParseCIDR(source.Prefix)
+	if err != nil {
+		return false, "", nil
+	}
+	count, err := publicAddressCount(prefix, checker.Cfg.PrivateRanges)
+	if err != nil {
+		return false, "", err
+	}
+	if count.Sign() == 0 {
+		return false, "", nil
+	}
+
+	overlap := ""
+	for _, name := range names {
+		blocks, err := parseCIDRs(checker.Cfg.CIDRSets[name])
+		if err != nil {
+			return false, "", err
+		}
+		for _, block := range blocks {
+			if prefixContains(block, prefix) {
+				return false, "", nil
+			}
+			if overlap == "" && intersectionSize(block, prefix).Sign() > 0 {
+				overlap = name
+			}
+		}
+	}
+	return true, overlap, nil
+}
+
+// prefixContains returns true if inner is a subnet of outer.
+func prefixContains(outer, inner *net.IPNet) bool {
+	outerOnes, outerBits := outer.Mask.Size()
+	innerOnes, innerBits := inner.Mask.Size()
+	return outerBits == innerBits && innerOnes >= outerOnes && outer.Contains(inner.IP)
+}
+
+// cidrSetsDocument converts the CIDR sets into a document that can be stored as Rego data.
+func cidrSetsDocument(sets map[string][]string) map[string]interface{} {
+	doc := map[string]interface{}{}
+	for name, cidrs := range sets {
+		items := []interface{}{}
+		for _, cidr := range cidrs {
+			items = append(items, cidr)
+		}
+		doc[name] = items
+	}
+	return doc
+}
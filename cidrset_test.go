@@ -0,0 +1,59 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gophercloud/gophercloud/openstack/identity/v3/projects"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/security/groups"
+)
+
+func TestIsUntrustedSource(t *testing.T) {
+	checker := newTestChecker()
+	checker.Projects = []projects.Project{{ID: "p1", Name: "web"}}
+	checker.Cfg.CIDRSets = map[string][]string{
+		"office": {"198.51.100.0/24"},
+		"vpn":    {"203.0.113.0/25", "2001:db8:1::/48"},
+		"global": {"192.0.2.0/24"},
+	}
+	checker.Cfg.TrustedCIDRSets = []string{"global"}
+	checker.Cfg.Rules = []Rule{{Tenant: "web", TenantID: "p1", SG: "web", CIDRSets: []string{"office", "vpn"}}}
+
+	web := groups.SecGroup{ID: "sg-web", Name: "web", TenantID: "p1"}
+	db := groups.SecGroup{ID: "sg-db", Name: "db", TenantID: "p1"}
+	tests := []struct {
+		name      string
+		sg        groups.SecGroup
+		source    ruleSource
+		untrusted bool
+		overlap   string
+	}{
+		{"in a set of the rule", web, ruleSource{Prefix: "198.51.100.0/25"}, false, ""},
+		{"in another block of a set", web, ruleSource{Prefix: "203.0.113.10/32"}, false, ""},
+		{"in the global set", web, ruleSource{Prefix: "192.0.2.1/32"}, false, ""},
+		{"global set of another group", db, ruleSource{Prefix: "192.0.2.1/32"}, false, ""},
+		{"set of the rule doesn't apply to another group", db, ruleSource{Prefix: "198.51.100.1/32"}, true, ""},
+		{"partial overlap is named", web, ruleSource{Prefix: "198.51.100.0/23"}, true, "office"},
+		{"partial overlap of a block", web, ruleSource{Prefix: "203.0.113.0/24"}, true, "vpn"},
+		{"outside every set", web, ruleSource{Prefix: "8.8.8.0/24"}, true, ""},
+		{"world overlaps the global set first", web, ruleSource{Prefix: "0.0.0.0/0"}, true, "global"},
+		{"IPv6 in a set", web, ruleSource{Prefix: "2001:db8:1:2::/64"}, false, ""},
+		{"IPv6 outside every set", web, ruleSource{Prefix: "2600::/64"}, true, ""},
+		{"private source", web, ruleSource{Prefix: "10.0.0.0/8"}, false, ""},
+		{"remote group", web, ruleSource{RemoteGroupID: "sg-lb"}, false, ""},
+	}
+	for _, tt := range tests {
+		untrusted, overlap, err := checker.isUntrustedSource(tt.sg, tt.source)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if untrusted != tt.untrusted || overlap != tt.overlap {
+			t.Errorf("%s: isUntrustedSource(%s) = %v, %q, want %v, %q", tt.name, tt.source, untrusted, overlap, tt.untrusted, tt.overlap)
+		}
+	}
+
+	// Without trusted sets, every source is trusted.
+	checker.Cfg.TrustedCIDRSets, checker.Cfg.Rules = nil, nil
+	if untrusted, _, _ := checker.isUntrustedSource(web, ruleSource{Prefix: "0.0.0.0/0"}); untrusted {
+		t.Error("the world is untrusted without trusted sets")
+	}
+}
@@ -1,8 +1,11 @@
 package main
 
 import (
+	"fmt"
+	"net"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/BurntSushi/toml"
 	"github.com/go-playground/validator/v10"
@@ -22,8 +25,109 @@ type Config struct {
 	SlackToken    string
 	PrefixMessage string `toml:"prefix_message" validate:"required"`
 	SuffixMessage string `toml:"suffix_message" validate:"required"`
-	OpenStack     OpenStack
-	Policies      []Policy
+	// Provider is the cloud to check: "openstack" (default), "aws", "gcp" or "azure".
+	Provider  string `toml:"provider" validate:"omitempty,oneof=openstack aws gcp azure"`
+	OpenStack OpenStack
+	AWS       AWS
+	GCP       GCP
+	Azure     Azure
+	Policies  []Policy
+	// MaxPublicPrefixSize is the largest public address space (as a prefix length) that an ingress
+	// rule may expose, e.g. 16 flags rules exposing more public addresses than a /16. 0 disables it.
+	MaxPublicPrefixSize   int `toml:"max_public_prefix_size" validate:"min=0,max=32"`
+	MaxPublicPrefixSizeV6 int `toml:"max_public_prefix_size_v6" validate:"min=0,max=128"`
+	// CIDRSets are named lists of CIDRs (e.g. office, vpn) that can be referenced by rules and
+	// are available in Rego as data.cidr_sets.
+	CIDRSets        map[string][]string `toml:"cidr_sets"`
+	TrustedCIDRSets []string            `toml:"trusted_cidr_sets"`
+	// PrivateRanges are CIDRs treated as internal in addition to RFC1918, RFC6598 and fc00::/7,
+	// which are always internal.
+	PrivateRanges []string `toml:"private_ranges"`
+	// InternalNetworks and ExternalNetworks override the classification of fixed IPs on the
+	// Neutron networks with the given ID or name.
+	InternalNetworks []string `toml:"internal_networks"`
+	ExternalNetworks []string `toml:"external_networks"`
+	// TransitiveExposure reports groups reachable from the world through remote group references.
+	TransitiveExposure bool `toml:"transitive_exposure"`
+	// PortSecurityCheck reports public ports with port security disabled.
+	PortSecurityCheck bool `toml:"port_security_check"`
+	// AddressPairCheck reports ports whose allowed address pairs are broader than
+	// min_address_pair_prefix_length (any address is always reported) or more than max_address_pairs.
+	AddressPairCheck             bool `toml:"address_pair_check"`
+	MinAddressPairPrefixLength   int  `toml:"min_address_pair_prefix_length" validate:"min=0,max=32"`
+	MinAddressPairPrefixLengthV6 int  `toml:"min_address_pair_prefix_length_v6" validate:"min=0,max=128"`
+	MaxAddressPairs              int  `toml:"max_address_pairs" validate:"min=0"`
+	Egress                       Egress
+	// MetadataKeys are server metadata keys (e.g. owner) included in findings. Defaults to owner.
+	MetadataKeys []string `toml:"metadata_keys"`
+	// LoadBalancerCheck reports listeners of load balancers with a public VIP. Listeners whose
+	// allowed_cidrs don't exceed max_public_prefix_size (a /8, or /32 for IPv6, when not set) are
+	// not reported.
+	LoadBalancerCheck bool `toml:"load_balancer_check"`
+	Hygiene           Hygiene
+	// Notifiers are channels warnings are sent to in addition to the Slack channel of
+	// SLACK_CHANNEL_NAME. Unless dry run, either SLACK_TOKEN or a notifier is required.
+	Notifiers []NotifierConfig `toml:"notifiers"`
+	// StrictRules refuses allow rules without a reason.
+	StrictRules bool `toml:"strict_rules"`
+	// ExpiryReminderDays warns of rules expiring within this number of days on every check and
+	// lists them in the hygiene digest if enabled. It defaults to defaultExpiryReminderDays.
+	ExpiryReminderDays int `toml:"expiry_reminder_days" validate:"min=0"`
+}
+
+// Hygiene configures the periodic digest of unused, stale and duplicate security groups.
+type Hygiene struct {
+	Enabled bool `toml:"enabled"`
+	// Interval is a cron spec, e.g. "0 0 10 * * MON".
+	Interval string `toml:"interval" validate:"required_with=Enabled"`
+	// StaleDays reports groups not updated for this number of days. 0 disables it.
+	StaleDays     int    `toml:"stale_days" validate:"min=0"`
+	PrefixMessage string `toml:"prefix_message" validate:"required_with=Enabled"`
+	SuffixMessage string `toml:"suffix_message" validate:"required_with=Enabled"`
+}
+
+// NotifierConfig configures a channel warnings are sent to.
+type NotifierConfig struct {
+	// Type is "slack", "webhook", "mattermost", "teams" or "email".
+	Type string `toml:"type"`
+	// URL is the webhook URL. Slack posts with SLACK_TOKEN instead.
+	URL string `toml:"url"`
+	// Secret signs the body of generic webhook requests with HMAC-SHA256.
+	Secret string `toml:"secret"`
+	// Channel overrides the channel of Slack and Mattermost.
+	Channel string `toml:"channel"`
+	SMTP    SMTP   `toml:"smtp"`
+}
+
+// SMTP configures the email notifier.
+type SMTP struct {
+	Host string `toml:"host"`
+	// Port defaults to 587.
+	Port     int    `toml:"port"`
+	Username string `toml:"username"`
+	// Password defaults to SMTP_PASSWORD.
+	Password string `toml:"password"`
+	// StartTLS requires upgrading the connection with STARTTLS before authenticating.
+	StartTLS bool   `toml:"starttls"`
+	CACert   string `toml:"ca_cert"`
+	From     string `toml:"from"`
+	Subject  string `toml:"subject"`
+	// To receive every digest, including findings of tenants without recipients and the hygiene digest.
+	To []string `toml:"to"`
+	// Recipients maps tenants to addresses receiving the findings of the tenant. Tenants are names,
+	// IDs, globs or regular expressions enclosed in slashes.
+	Recipients map[string][]string `toml:"recipients"`
+}
+
+// Egress configures the audit of egress rules of sensitive projects.
+type Egress struct {
+	Enabled bool `toml:"enabled"`
+	// Projects are the sensitive projects, required when enabled. They are project names, IDs,
+	// domain-qualified names, globs or regular expressions like tenants of rules. Most projects have
+	// Neutron's default egress rules, so auditing all of them would report nearly every group.
+	Projects      []string `toml:"projects"`
+	PrefixMessage string   `toml:"prefix_message" validate:"required_with=Enabled"`
+	SuffixMessage string   `toml:"suffix_message" validate:"required_with=Enabled"`
 }
 
 type OpenStack struct {
@@ -37,15 +141,104 @@ type OpenStack struct {
 	Key         string
 }
 
+// AWS configures the EC2 provider. Credentials are read from the environment or the shared
+// config files as usual for the AWS SDK.
+type AWS struct {
+	Region string `toml:"region"`
+	// Endpoint overrides the EC2 endpoint, e.g. a local fake EC2 for testing.
+	Endpoint string `toml:"endpoint"`
+	// Accounts maps account IDs to names used as tenants in rules and findings.
+	Accounts map[string]string `toml:"accounts"`
+}
+
+// GCP configures the VPC firewall provider. Credentials are found by the application default
+// credentials, e.g. GOOGLE_APPLICATION_CREDENTIALS.
+type GCP struct {
+	Projects []string `toml:"projects"`
+	// Endpoint overrides the Compute Engine endpoint, e.g. "http://localhost:8080/compute/v1/projects/"
+	// of a local fake for testing. Requests to it are not authenticated.
+	Endpoint string `toml:"endpoint"`
+}
+
+// Azure configures the network security group provider. Credentials are read from the
+// environment, e.g. AZURE_TENANT_ID, AZURE_CLIENT_ID and AZURE_CLIENT_SECRET.
+type Azure struct {
+	Subscriptions []string `toml:"subscriptions"`
+	// SubscriptionNames maps subscription IDs to names used as tenants in rules and findings.
+	SubscriptionNames map[string]string `toml:"subscription_names"`
+	// Endpoint overrides the Resource Manager endpoint, e.g. a local fake for testing. Requests
+	// to it are not authenticated.
+	Endpoint string `toml:"endpoint"`
+}
+
 type Rule struct {
+	// Tenant is a project name, ID, domain-qualified name ("domain/project"), glob or regular
+	// expression enclosed in slashes. TenantID is the first project matching it.
 	Tenant   string
 	TenantID string
-	SG       string
-	Port     []string
+	// SG is a security group name, glob or regular expression. SGID matches a group by ID instead.
+	SG                    string
+	SGID                  string `toml:"sg_id"`
+	Port                  []string
+	MaxPublicPrefixSize   int      `toml:"max_public_prefix_size"`
+	MaxPublicPrefixSizeV6 int      `toml:"max_public_prefix_size_v6"`
+	CIDRSets              []string `toml:"cidr_sets"`
+	// Direction is "ingress" (default) or "egress".
+	Direction string
+	// Sources are CIDRs or names of cidr_sets. When set, an ingress rule is allowed only if its
+	// remote prefix is contained in one of them.
+	Sources []string
+	// Destinations are CIDRs or names of cidr_sets an egress rule may send traffic to.
+	Destinations []string
+	// LoadBalancer is the name or ID of a load balancer whose listener ports are allowed.
+	LoadBalancer string `toml:"load_balancer"`
+	// Expires is the date (2006-01-02 or RFC3339) after which the rule no longer suppresses findings.
+	Expires string `toml:"expires"`
+	Owner   string `toml:"owner"`
+	Reason  string `toml:"reason"`
+	Ticket  string `toml:"ticket"`
+	// Source is the config file the rule is read from.
+	Source string `toml:"-"`
+	// index is the position of the rule in Config.Rules.
+	index int
+}
+
+// expiresAt returns the time the rule expires at. The zero time means the rule never expires.
+// A date without time expires at the end of the day in local time.
+func (r Rule) expiresAt() (time.Time, error) {
+	if r.Expires == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, r.Expires); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", r.Expires, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid expires: %s", r.Expires)
+	}
+	return t.AddDate(0, 0, 1), nil
+}
+
+func (r Rule) expired(now time.Time) bool {
+	t, err := r.expiresAt()
+	if err != nil {
+		return true
+	}
+	return !t.IsZero() && !now.Before(t)
+}
+
+func (r Rule) direction() string {
+	if r.Direction == "" {
+		return "ingress"
+	}
+	return r.Direction
 }
 
 type Policy struct {
-	Policy        string `toml:"policy" validate:"required"`
+	Policy string `toml:"policy" validate:"required"`
+	// Resource is the input of the policy: "security_group" (default) or "port". A port input has
+	// the Neutron attributes of the port, e.g. port_security_enabled, with public_ips and instance.
+	Resource      string `toml:"resource" validate:"omitempty,oneof=security_group port"`
 	Data          string `toml:"data"`
 	PrefixMessage string `toml:"prefix_message" validate:"required"`
 	SuffixMessage string `toml:"suffix_message" validate:"required"`
@@ -65,6 +258,7 @@ func includeConfigFile(cfg *Config, include string) error {
 			return err
 		}
 		for _, r := range tmpCfg.Rules {
+			r.Source = file
 			cfg.Rules = append(cfg.Rules, r)
 		}
 	}
@@ -77,6 +271,9 @@ func ReadConfigFile(cfgPath string) (Config, error) {
 	if err != nil {
 		return cfg, err
 	}
+	for i := range cfg.Rules {
+		cfg.Rules[i].Source = cfgPath
+	}
 	if cfg.Include != "" {
 		if err := includeConfigFile(&cfg, cfg.Include); err != nil {
 			return cfg, err
@@ -94,6 +291,11 @@ func ReadConfig(cfgPath string, dryRun bool) (Config, error) {
 	cfg.DryRun = dryRun
 	cfg.SlackChannel = os.Getenv("SLACK_CHANNEL_NAME")
 	cfg.SlackToken = os.Getenv("SLACK_TOKEN")
+	for i, n := range cfg.Notifiers {
+		if n.Type == "email" && n.SMTP.Password == "" {
+			cfg.Notifiers[i].SMTP.Password = os.Getenv("SMTP_PASSWORD")
+		}
+	}
 
 	cfg.OpenStack.AuthURL = os.Getenv("OS_AUTH_URL")
 	cfg.OpenStack.Username = os.Getenv("OS_USERNAME")
@@ -104,9 +306,141 @@ func ReadConfig(cfgPath string, dryRun bool) (Config, error) {
 	cfg.OpenStack.Cert = os.Getenv("OS_CERT")
 	cfg.OpenStack.Key = os.Getenv("OS_KEY")
 
+	if len(cfg.MetadataKeys) == 0 {
+		cfg.MetadataKeys = []string{"owner"}
+	}
+	cfg.PrivateRanges = append(append([]string{}, defaultPrivateRanges...), cfg.PrivateRanges...)
+	if _, err := parseCIDRs(cfg.PrivateRanges); err != nil {
+		return cfg, fmt.Errorf("invalid private_ranges: %s", err)
+	}
+
 	validate := validator.New()
 	if err := validate.Struct(cfg); err != nil {
 		return cfg, err
 	}
+	if cfg.Egress.Enabled && len(cfg.Egress.Projects) == 0 {
+		return cfg, fmt.Errorf("egress.projects is required when egress is enabled")
+	}
+	for _, project := range cfg.Egress.Projects {
+		if err := validatePattern(project); err != nil {
+			return cfg, fmt.Errorf("%s in egress.projects", err)
+		}
+	}
+	if err := validateRules(cfg.Rules, cfg.StrictRules); err != nil {
+		return cfg, err
+	}
+	if err := validateCIDRSets(cfg); err != nil {
+		return cfg, err
+	}
+	if err := validateNotifiers(cfg.Notifiers); err != nil {
+		return cfg, err
+	}
+	if !cfg.DryRun {
+		if err := requireNotifier(cfg); err != nil {
+			return cfg, err
+		}
+	}
 	return cfg, nil
 }
+
+// requireNotifier refuses a config whose warnings would not be sent anywhere. The Slack channel of
+// SLACK_CHANNEL_NAME is only notified when SLACK_TOKEN is set.
+func requireNotifier(cfg Config) error {
+	if cfg.SlackToken == "" {
+		for i, n := range cfg.Notifiers {
+			if n.Type == "slack" {
+				return fmt.Errorf("notifiers[%d]: SLACK_TOKEN is required for slack", i)
+			}
+		}
+		if len(cfg.Notifiers) == 0 {
+			return fmt.Errorf("no notifier is configured: set SLACK_TOKEN and SLACK_CHANNEL_NAME, or add notifiers")
+		}
+	}
+	return nil
+}
+
+func validateNotifiers(notifiers []NotifierConfig) error {
+	for i, n := range notifiers {
+		switch n.Type {
+		case "slack":
+			if n.Channel == "" {
+				return fmt.Errorf("notifiers[%d]: channel is required for slack", i)
+			}
+		case "webhook", "mattermost", "teams":
+			if n.URL == "" {
+				return fmt.Errorf("notifiers[%d]: url is required for %s", i, n.Type)
+			}
+		case "email":
+			if n.SMTP.Host == "" || n.SMTP.From == "" {
+				return fmt.Errorf("notifiers[%d]: smtp.host and smtp.from are required for email", i)
+			}
+			for tenant := range n.SMTP.Recipients {
+				if err := validatePattern(tenant); err != nil {
+					return fmt.Errorf("notifiers[%d]: invalid recipients tenant %q: %s", i, tenant, err)
+				}
+			}
+		default:
+			return fmt.Errorf("notifiers[%d]: unknown type: %q", i, n.Type)
+		}
+	}
+	return nil
+}
+
+func validateCIDRSets(cfg Config) error {
+	for name, cidrs := range cfg.CIDRSets {
+		if _, err := parseCIDRs(cidrs); err != nil {
+			return fmt.Errorf("invalid cidr in cidr set %s: %s", name, err)
+		}
+	}
+	names := []string{}
+	names = append(names, cfg.TrustedCIDRSets...)
+	for _, r := range cfg.Rules {
+		names = append(names, r.CIDRSets...)
+		for _, d := range append(append([]string{}, r.Sources...), r.Destinations...) {
+			if _, _, err := net.ParseCIDR(d); err != nil {
+				names = append(names, d)
+			}
+		}
+	}
+	for _, name := range names {
+		if _, ok := cfg.CIDRSets[name]; !ok {
+			return fmt.Errorf("unknown cidr set: %s", name)
+		}
+	}
+	return nil
+}
+
+func validateRules(rules []Rule, strict bool) error {
+	for _, r := range rules {
+		for _, pattern := range []string{r.Tenant, r.SG} {
+			if err := validatePattern(pattern); err != nil {
+				return fmt.Errorf("%s in rule (tenant: %s, sg: %s, file: %s)", err, r.Tenant, r.SG, r.Source)
+			}
+		}
+		if strict && r.Reason == "" {
+			return fmt.Errorf("reason is required in rule (tenant: %s, sg: %s, file: %s)", r.Tenant, r.SG, r.Source)
+		}
+		if _, err := r.expiresAt(); err != nil {
+			return fmt.Errorf("%s in rule (tenant: %s, sg: %s, file: %s)", err, r.Tenant, r.SG, r.Source)
+		}
+		if r.direction() != "ingress" && r.direction() != "egress" {
+			return fmt.Errorf("invalid direction in rule (tenant: %s, sg: %s): %s", r.Tenant, r.SG, r.Direction)
+		}
+		for _, entry := range r.Port {
+			protocol, ports := parseAllowedPort(entry)
+			if protocol == "" {
+				return fmt.Errorf("empty port in rule (tenant: %s, sg: %s, file: %s)", r.Tenant, r.SG, r.Source)
+			}
+			if !isKnownProtocol(protocol) {
+				return fmt.Errorf("unknown protocol in rule (tenant: %s, sg: %s): %s", r.Tenant, r.SG, entry)
+			}
+			if ports == "" || !protocolHasPorts(protocol) {
+				continue
+			}
+			if _, err := parsePortRanges(ports); err != nil {
+				return fmt.Errorf("invalid port in rule (tenant: %s, sg: %s): %s", r.Tenant, r.SG, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const baseConfig = `
+username = "sg_inspector"
+icon_emoji = ":shield:"
+check_interval = "0 0 * * * *"
+reset_interval = "0 0 0 * * *"
+prefix_message = "prefix"
+suffix_message = "suffix"
+`
+
+func readTestConfig(t *testing.T, body string) (Config, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := ioutil.WriteFile(path, []byte(baseConfig+body), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return ReadConfig(path, true)
+}
+
+// setenv sets the environment variable until the end of the test.
+func setenv(t *testing.T, key, value string) {
+	old, ok := os.LookupEnv(key)
+	os.Setenv(key, value)
+	t.Cleanup(func() {
+		if ok {
+			os.Setenv(key, old)
+		} else {
+			os.Unsetenv(key)
+		}
+	})
+}
+
+func TestReadConfigPrivateRanges(t *testing.T) {
+	cfg, err := readTestConfig(t, `private_ranges = ["203.0.113.0/24"]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := append(append([]string{}, defaultPrivateRanges...), "203.0.113.0/24")
+	if !reflect.DeepEqual(cfg.PrivateRanges, want) {
+		t.Errorf("PrivateRanges = %v, want %v", cfg.PrivateRanges, want)
+	}
+
+	cfg, err = readTestConfig(t, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cfg.PrivateRanges, defaultPrivateRanges) {
+		t.Errorf("PrivateRanges = %v, want %v", cfg.PrivateRanges, defaultPrivateRanges)
+	}
+
+	if _, err := readTestConfig(t, `private_ranges = ["203.0.113.0"]`); err == nil {
+		t.Error("invalid private_ranges is accepted")
+	}
+}
+
+func TestReadConfigEgressProjects(t *testing.T) {
+	egress := `
+[egress]
+enabled = true
+prefix_message = "prefix"
+suffix_message = "suffix"
+`
+	if _, err := readTestConfig(t, egress); err == nil {
+		t.Error("egress without projects is accepted")
+	}
+	if _, err := readTestConfig(t, egress+`projects = ["payment"]`); err != nil {
+		t.Errorf("egress with projects is refused: %s", err)
+	}
+	if _, err := readTestConfig(t, egress+`projects = ["/pay(/"]`); err == nil {
+		t.Error("invalid pattern in egress.projects is accepted")
+	}
+}
+
+func TestReadConfigExpiryReminder(t *testing.T) {
+	rule := `
+[[rules]]
+tenant = "web"
+sg = "web"
+port = ["443"]
+expires = "2030-01-01"
+`
+	tests := []struct {
+		name    string
+		body    string
+		wantErr bool
+	}{
+		{"without reminder days", rule, false},
+		{"with reminder days", "expiry_reminder_days = 30\n" + rule, false},
+		{"negative reminder days", "expiry_reminder_days = -1\n" + rule, true},
+		{"invalid expires", strings.Replace(rule, "2030-01-01", "soon", 1), true},
+	}
+	for _, tt := range tests {
+		_, err := readTestConfig(t, tt.body)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestReadConfigRequiresNotifier(t *testing.T) {
+	webhook := `
+[[notifiers]]
+type = "webhook"
+url = "https://example.com/hook"
+`
+	slack := `
+[[notifiers]]
+type = "slack"
+channel = "#security"
+`
+	tests := []struct {
+		name    string
+		token   string
+		body    string
+		dryRun  bool
+		wantErr bool
+	}{
+		{"nothing", "", "", false, true},
+		{"nothing in dry run", "", "", true, false},
+		{"slack token", "xoxb-token", "", false, false},
+		{"webhook", "", webhook, false, false},
+		{"slack without token", "", slack, false, true},
+		{"slack with token", "xoxb-token", slack, false, false},
+	}
+	for _, tt := range tests {
+		setenv(t, "SLACK_TOKEN", tt.token)
+		path := filepath.Join(t.TempDir(), "config.toml")
+		if err := ioutil.WriteFile(path, []byte(baseConfig+tt.body), 0600); err != nil {
+			t.Fatal(err)
+		}
+		_, err := ReadConfig(path, tt.dryRun)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
@@ -25,12 +25,22 @@ func StartCron(c *cli.Context) error {
 	server := cron.New()
 	logrus.Infof("check interval: %s", checker.Cfg.CheckInterval)
 	server.AddFunc(checker.Cfg.CheckInterval, func() {
-		err := checker.Run()
+		_, err := checker.Run()
 		if err != nil {
 			logrus.Errorf("%+v\n", err)
 		}
 	})
 
+	if checker.Cfg.Hygiene.Enabled {
+		logrus.Infof("hygiene interval: %s", checker.Cfg.Hygiene.Interval)
+		server.AddFunc(checker.Cfg.Hygiene.Interval, func() {
+			err := checker.RunHygiene()
+			if err != nil {
+				logrus.Errorf("%+v\n", err)
+			}
+		})
+	}
+
 	server.Run()
 
 	return nil
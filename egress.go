@@ -0,0 +1,121 @@
+package main
+
+import (
+	"net"
+
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/security/groups"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/security/rules"
+)
+
+// isSensitiveProject returns true if egress of the project should be audited. Sensitive projects
+// are matched like tenants of rules. No project is audited when none is configured.
+func (checker *OpenStackSecurityGroupChecker) isSensitiveProject(projectID string) bool {
+	for _, tenant := range checker.Cfg.Egress.Projects {
+		if checker.matchTenant(tenant, projectID) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveCIDRs resolves sources or destinations of a rule, which are CIDRs or names of cidr_sets.
+func (checker *OpenStackSecurityGroupChecker) resolveCIDRs(items []string) []string {
+	cidrs := []string{}
+	for _, d := range items {
+		if _, _, err := net.ParseCIDR(d); err == nil {
+			cidrs = append(cidrs, d)
+			continue
+		}
+		cidrs = append(cidrs, checker.Cfg.CIDRSets[d]...)
+	}
+	return cidrs
+}
+
+// containedIn returns true if the prefix is contained in one of the CIDRs or cidr_sets.
+func (checker *OpenStackSecurityGroupChecker) containedIn(items []string, prefix *net.IPNet) bool {
+	blocks, err := parseCIDRs(checker.resolveCIDRs(items))
+	if err != nil {
+		return false
+	}
+	for _, block := range blocks {
+		if prefixContains(block, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchAllowdEgressRule returns true if an egress rule of allowdRules covers both the destination
+// and the ports of the rule. An allow rule with destinations but without ports allows every port.
+func (checker *OpenStackSecurityGroupChecker) matchAllowdEgressRule(sg groups.SecGroup, rule rules.SecGroupRule, destination *net.IPNet) (bool, portRanges) {
+	matched := []Rule{}
+	for _, allowdRule := range checker.activeRules() {
+		if !checker.matchGroup(allowdRule, sg) || allowdRule.direction() != "egress" {
+			continue
+		}
+		if len(allowdRule.Destinations) > 0 {
+			if !checker.containedIn(allowdRule.Destinations, destination) {
+				continue
+			}
+			if len(allowdRule.Port) == 0 {
+				if checker.suppressing != nil {
+					checker.suppressing[allowdRule.index] = true
+				}
+				return true, nil
+			}
+		}
+		matched = append(matched, allowdRule)
+	}
+	allowd, uncovered := coverPorts(matched, rule)
+	if allowd {
+		checker.markSuppressing(matched, rule)
+	}
+	return allowd, uncovered
+}
+
+// findUnrestrictedEgress reports egress rules of sensitive projects that allow traffic to public
+// addresses which are not whitelisted by egress rules in the config.
+func (checker *OpenStackSecurityGroupChecker) findUnrestrictedEgress(securityGroups []groups.SecGroup, ports []neutronPort, allowed_sg []string) ([]Finding, error) {
+	findings := []Finding{}
+	for _, sg := range securityGroups {
+		if !checker.isSensitiveProject(sg.TenantID) || len(memberPortIDs(sg, ports)) == 0 {
+			continue
+		}
+		if contain(allowed_sg, sg.ID) {
+			continue
+		}
+		for _, rule := range sg.Rules {
+			if rule.Direction != "egress" {
+				continue
+			}
+			destination := effectiveSource(rule)
+			if destination.RemoteGroupID != "" {
+				continue
+			}
+			_, prefix, err := net.ParseCIDR(destination.Prefix)
+			if err != nil {
+				continue
+			}
+			count, err := publicAddressCount(prefix, checker.Cfg.PrivateRanges)
+			if err != nil {
+				return nil, err
+			}
+			if count.Sign() == 0 {
+				continue
+			}
+			allowd, uncovered := checker.matchAllowdEgressRule(sg, rule, prefix)
+			if allowd {
+				continue
+			}
+
+			finding := checker.newFinding(CheckUnrestrictedEgress, SeverityHigh, sg.TenantID, ResourceSecurityGroup, sg.ID, sg.Name)
+			finding.Rule = newFindingRule(rule)
+			finding.Ports = memberPortIDs(sg, ports)
+			if len(uncovered) > 0 && uncovered.String() != rulePortRange(rule).String() {
+				finding.addDetail("Uncovered", uncovered.String())
+			}
+			findings = append(findings, finding)
+		}
+	}
+	return findings, nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gophercloud/gophercloud/openstack/identity/v3/domains"
+	"github.com/gophercloud/gophercloud/openstack/identity/v3/projects"
+)
+
+func TestIsSensitiveProject(t *testing.T) {
+	checker := newTestChecker()
+	checker.Projects = []projects.Project{{ID: "p1", Name: "payment"}, {ID: "p2", Name: "web"}}
+
+	if checker.isSensitiveProject("p1") || checker.isSensitiveProject("p2") {
+		t.Error("a project is sensitive without egress.projects")
+	}
+
+	checker.Cfg.Egress.Projects = []string{"payment"}
+	if !checker.isSensitiveProject("p1") {
+		t.Error("payment is not sensitive")
+	}
+	if checker.isSensitiveProject("p2") {
+		t.Error("web is sensitive")
+	}
+	if checker.isSensitiveProject("unknown") {
+		t.Error("unknown project is sensitive")
+	}
+
+	checker.Domains = []domains.Domain{{ID: "default", Name: "Default"}, {ID: "d-ops", Name: "ops"}}
+	checker.Projects = append(checker.Projects, projects.Project{ID: "p3", Name: "payment", DomainID: "d-ops"})
+	for i := range checker.Projects[:2] {
+		checker.Projects[i].DomainID = "default"
+	}
+	tests := []struct {
+		pattern   string
+		projectID string
+		want      bool
+	}{
+		{"p1", "p1", true},
+		{"p1", "p2", false},
+		{"pay*", "p1", true},
+		{"pay*", "p2", false},
+		{"/^pay/", "p1", true},
+		{"/^pay/", "p2", false},
+		{"/^ops/pay/", "p3", true},
+		{"Default/payment", "p1", true},
+		{"Default/payment", "p3", false},
+		{"ops/*", "p3", true},
+		{"ops/*", "p2", false},
+	}
+	for _, tt := range tests {
+		checker.Cfg.Egress.Projects = []string{tt.pattern}
+		if got := checker.isSensitiveProject(tt.projectID); got != tt.want {
+			t.Errorf("isSensitiveProject(%q) with %q = %v, want %v", tt.projectID, tt.pattern, got, tt.want)
+		}
+	}
+}
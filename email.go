@@ -0,0 +1,304 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	htmltemplate "html/template"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"sort"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+const defaultEmailSubject = "Security group warnings"
+
+// emailDigest is the content of a mail to a recipient.
+type emailDigest struct {
+	Prefix   string
+	Suffix   string
+	Findings []Finding
+	Sections []Section
+}
+
+var emailTextTemplate = texttemplate.Must(texttemplate.New("text").Funcs(texttemplate.FuncMap{"join": strings.Join}).Parse(`{{range .}}{{.Prefix}}
+{{range .Findings}}
+[{{.Severity}}] {{.CheckID}}: {{.Project}} / {{.ResourceName}} ({{.ResourceID}})
+{{- if .Rule}}
+  Rule: {{.Rule.Direction}} {{.Rule.Protocol}} {{.Rule.PortRange}} {{.Rule.Remote}}
+{{- end}}
+{{- if .IPs}}
+  IPs: {{join .IPs ", "}}
+{{- end}}
+{{- range .Details}}
+  {{.Title}}: {{.Value}}
+{{- end}}
+{{- if not .FirstSeen.IsZero}}
+  First seen: {{.FirstSeen.Format "2006-01-02 15:04"}}
+{{- end}}
+{{end}}
+{{- range .Sections}}
+{{.Title}}
+{{- range .Items}}
+  - {{.}}
+{{- end}}
+{{end}}
+{{.Suffix}}
+{{end}}`))
+
+var emailHTMLTemplate = htmltemplate.Must(htmltemplate.New("html").Parse(`<!DOCTYPE html>
+<html>
+<body>
+{{- range .}}
+<p>{{.Prefix}}</p>
+{{- if .Findings}}
+<table border="1" cellpadding="4" cellspacing="0">
+<tr><th>Severity</th><th>Check</th><th>Tenant</th><th>Resource</th><th>Rule</th><th>IPs</th><th>Details</th><th>First seen</th></tr>
+{{- range .Findings}}
+<tr>
+<td>{{.Severity}}</td>
+<td>{{.CheckID}}</td>
+<td>{{.Project}}</td>
+<td>{{.ResourceName}}<br><small>{{.ResourceID}}</small></td>
+<td>{{if .Rule}}{{.Rule.Direction}} {{.Rule.Protocol}} {{.Rule.PortRange}}<br>{{.Rule.Remote}}{{end}}</td>
+<td>{{range .IPs}}{{.}}<br>{{end}}</td>
+<td>{{range .Details}}<b>{{.Title}}</b>: {{.Value}}<br>{{end}}</td>
+<td>{{if not .FirstSeen.IsZero}}{{.FirstSeen.Format "2006-01-02 15:04"}}{{end}}</td>
+</tr>
+{{- end}}
+</table>
+{{- end}}
+{{- range .Sections}}
+<h4>{{.Title}}</h4>
+<ul>
+{{- range .Items}}
+<li>{{.}}</li>
+{{- end}}
+</ul>
+{{- end}}
+<p>{{.Suffix}}</p>
+{{- end}}
+</body>
+</html>
+`))
+
+// emailNotifier sends a digest to each recipient with the findings of the tenants they receive,
+// as a multipart mail with plain-text and HTML bodies. It is a batch notifier, so that a check
+// sends a single mail to each recipient.
+type emailNotifier struct {
+	Cfg SMTP
+}
+
+func (e *emailNotifier) Notify(n Notification) error {
+	return e.NotifyAll([]Notification{n})
+}
+
+// NotifyAll sends a mail to each recipient with the digests of all the notifications.
+func (e *emailNotifier) NotifyAll(ns []Notification) error {
+	digests := map[string][]*emailDigest{}
+	for _, n := range ns {
+		for address, digest := range e.digests(n) {
+			digests[address] = append(digests[address], digest)
+		}
+	}
+	addresses := []string{}
+	for address := range digests {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+
+	// A failure of a recipient doesn't keep the others from receiving their digest.
+	failures := []string{}
+	for _, address := range addresses {
+		msg, err := e.message(address, digests[address])
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %s", address, err))
+			continue
+		}
+		if err := e.send([]string{address}, msg); err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %s", address, err))
+		}
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("Failed to send mail to %d of %d recipients: %s", len(failures), len(addresses), strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+// digests groups the findings by recipient. Findings go to the recipients of their tenant and to
+// the default recipients, sections only to the default recipients.
+func (e *emailNotifier) digests(n Notification) map[string]*emailDigest {
+	digests := map[string]*emailDigest{}
+	digest := func(address string) *emailDigest {
+		if _, ok := digests[address]; !ok {
+			digests[address] = &emailDigest{Prefix: n.Prefix, Suffix: n.Suffix}
+		}
+		return digests[address]
+	}
+	for _, f := range n.Findings {
+		for _, address := range e.recipientsOf(f) {
+			d := digest(address)
+			d.Findings = append(d.Findings, f)
+		}
+	}
+	if len(n.Sections) > 0 {
+		for _, address := range e.Cfg.To {
+			d := digest(address)
+			d.Sections = append(d.Sections, n.Sections...)
+		}
+	}
+	return digests
+}
+
+// recipientsOf returns the default recipients and the recipients of the tenant of the finding.
+// Tenants are names, IDs, globs or regular expressions enclosed in slashes.
+func (e *emailNotifier) recipientsOf(f Finding) []string {
+	results := []string{}
+	add := func(addresses []string) {
+		for _, address := range addresses {
+			if !contain(results, address) {
+				results = append(results, address)
+			}
+		}
+	}
+	add(e.Cfg.To)
+	tenants := []string{}
+	for tenant := range e.Cfg.Recipients {
+		tenants = append(tenants, tenant)
+	}
+	sort.Strings(tenants)
+	for _, tenant := range tenants {
+		if tenant == f.ProjectID || matchName(tenant, f.Project) {
+			add(e.Cfg.Recipients[tenant])
+		}
+	}
+	return results
+}
+
+func (e *emailNotifier) subject() string {
+	if e.Cfg.Subject != "" {
+		return e.Cfg.Subject
+	}
+	return defaultEmailSubject
+}
+
+// message builds a multipart/alternative mail with quoted-printable plain-text and HTML parts.
+func (e *emailNotifier) message(to string, digests []*emailDigest) ([]byte, error) {
+	var text, html bytes.Buffer
+	if err := emailTextTemplate.Execute(&text, digests); err != nil {
+		return nil, err
+	}
+	if err := emailHTMLTemplate.Execute(&html, digests); err != nil {
+		return nil, err
+	}
+
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	for _, part := range []struct {
+		contentType string
+		content     []byte
+	}{
+		{"text/plain; charset=UTF-8", text.Bytes()},
+		{"text/html; charset=UTF-8", html.Bytes()},
+	} {
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Type", part.contentType)
+		header.Set("Content-Transfer-Encoding", "quoted-printable")
+		pw, err := w.CreatePart(header)
+		if err != nil {
+			return nil, err
+		}
+		qw := quotedprintable.NewWriter(pw)
+		if _, err := qw.Write(part.content); err != nil {
+			return nil, err
+		}
+		if err := qw.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", e.Cfg.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", to)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("UTF-8", e.subject()))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: multipart/alternative; boundary=%s\r\n", w.Boundary())
+	fmt.Fprintf(&msg, "\r\n")
+	msg.Write(body.Bytes())
+	return msg.Bytes(), nil
+}
+
+// send delivers the message over SMTP, upgrading the connection with STARTTLS if configured.
+func (e *emailNotifier) send(to []string, msg []byte) error {
+	port := e.Cfg.Port
+	if port == 0 {
+		port = 587
+	}
+	c, err := smtp.Dial(net.JoinHostPort(e.Cfg.Host, strconv.Itoa(port)))
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if e.Cfg.StartTLS {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("%s does not support STARTTLS", e.Cfg.Host)
+		}
+		tlsConfig := &tls.Config{ServerName: e.Cfg.Host}
+		if e.Cfg.CACert != "" {
+			pem, err := ioutil.ReadFile(e.Cfg.CACert)
+			if err != nil {
+				return err
+			}
+			pool := x509.NewCertPool()
+			pool.AppendCertsFromPEM(pem)
+			tlsConfig.RootCAs = pool
+		}
+		if err := c.StartTLS(tlsConfig); err != nil {
+			return err
+		}
+	}
+	if e.Cfg.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", e.Cfg.Username, e.Cfg.Password, e.Cfg.Host)); err != nil {
+			return err
+		}
+	}
+
+	from, err := mail.ParseAddress(e.Cfg.From)
+	if err != nil {
+		return err
+	}
+	if err := c.Mail(from.Address); err != nil {
+		return err
+	}
+	for _, address := range to {
+		if err := c.Rcpt(address); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
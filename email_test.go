@@ -0,0 +1,328 @@
+package main
+
+import (
+	"bufio"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// smtpMail is a mail received by fakeSMTP.
+type smtpMail struct {
+	From string
+	To   []string
+	Data string
+}
+
+// fakeSMTP is an in-process SMTP server without extensions such as STARTTLS. It accepts mails
+// except to the rejected recipients.
+type fakeSMTP struct {
+	listener net.Listener
+	rejected []string
+
+	mu    sync.Mutex
+	mails []smtpMail
+}
+
+func newFakeSMTP(t *testing.T, rejected ...string) *fakeSMTP {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeSMTP{listener: l, rejected: rejected}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { l.Close() })
+	return s
+}
+
+func (s *fakeSMTP) port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+func (s *fakeSMTP) serve(conn net.Conn) {
+	defer conn.Close()
+	c := textproto.NewConn(conn)
+	c.PrintfLine("220 localhost ESMTP")
+	m := smtpMail{}
+	for {
+		line, err := c.ReadLine()
+		if err != nil {
+			return
+		}
+		verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch verb {
+		case "EHLO", "HELO":
+			c.PrintfLine("250 localhost")
+		case "MAIL":
+			m = smtpMail{From: smtpAddress(line)}
+			c.PrintfLine("250 OK")
+		case "RCPT":
+			address := smtpAddress(line)
+			if contain(s.rejected, address) {
+				c.PrintfLine("550 no such user")
+				continue
+			}
+			m.To = append(m.To, address)
+			c.PrintfLine("250 OK")
+		case "DATA":
+			c.PrintfLine("354 go ahead")
+			data, err := ioutil.ReadAll(c.DotReader())
+			if err != nil {
+				return
+			}
+			m.Data = string(data)
+			s.mu.Lock()
+			s.mails = append(s.mails, m)
+			s.mu.Unlock()
+			c.PrintfLine("250 OK")
+		case "QUIT":
+			c.PrintfLine("221 bye")
+			return
+		default:
+			c.PrintfLine("502 not implemented")
+		}
+	}
+}
+
+// smtpAddress returns the address in angle brackets of a MAIL or RCPT command.
+func smtpAddress(line string) string {
+	start, end := strings.Index(line, "<"), strings.Index(line, ">")
+	if start < 0 || end < start {
+		return ""
+	}
+	return line[start+1 : end]
+}
+
+// received returns the mails by recipient.
+func (s *fakeSMTP) received() map[string]smtpMail {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	results := map[string]smtpMail{}
+	for _, m := range s.mails {
+		for _, to := range m.To {
+			results[to] = m
+		}
+	}
+	return results
+}
+
+// all returns the received mails in order.
+func (s *fakeSMTP) all() []smtpMail {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]smtpMail{}, s.mails...)
+}
+
+// mailParts returns the decoded bodies of the mail by content type.
+func mailParts(t *testing.T, data string) map[string]string {
+	t.Helper()
+	msg, err := mail.ReadMessage(bufio.NewReader(strings.NewReader(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Content-Type = %q, want multipart/alternative", msg.Header.Get("Content-Type"))
+	}
+	results := map[string]string{}
+	r := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := r.NextPart()
+		if err != nil {
+			break
+		}
+		body, err := ioutil.ReadAll(part)
+		if err != nil {
+			t.Fatal(err)
+		}
+		results[part.Header.Get("Content-Type")] = string(body)
+	}
+	return results
+}
+
+func testEmailNotification() Notification {
+	return Notification{
+		Prefix: "Warnings:",
+		Suffix: "Bye",
+		Findings: []Finding{
+			{CheckID: CheckWorldOpen, Severity: SeverityHigh, ProjectID: "p-web", Project: "web-prod", ResourceID: "sg-web", ResourceName: "web-sg"},
+			{CheckID: CheckWorldOpen, Severity: SeverityHigh, ProjectID: "p-db", Project: "db", ResourceID: "sg-db", ResourceName: "db-sg"},
+		},
+		Sections: []Section{{Title: "Unused security groups", Items: []string{"old-sg"}}},
+	}
+}
+
+func TestEmailNotifierRouting(t *testing.T) {
+	server := newFakeSMTP(t)
+	e := &emailNotifier{Cfg: SMTP{
+		Host: "127.0.0.1",
+		Port: server.port(),
+		From: "sg_inspector <noreply@example.com>",
+		To:   []string{"security@example.com"},
+		Recipients: map[string][]string{
+			"web-*": {"web@example.com"},
+			"p-db":  {"db@example.com", "security@example.com"},
+		},
+	}}
+	if err := e.Notify(testEmailNotification()); err != nil {
+		t.Fatal(err)
+	}
+
+	received := server.received()
+	addresses := []string{}
+	for address := range received {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+	if want := []string{"db@example.com", "security@example.com", "web@example.com"}; !reflect.DeepEqual(addresses, want) {
+		t.Fatalf("recipients = %v, want %v", addresses, want)
+	}
+
+	tests := []struct {
+		address string
+		want    []string
+		notWant []string
+	}{
+		{"security@example.com", []string{"web-sg", "db-sg", "Unused security groups", "old-sg"}, nil},
+		{"web@example.com", []string{"web-sg"}, []string{"db-sg", "Unused security groups"}},
+		{"db@example.com", []string{"db-sg"}, []string{"web-sg", "Unused security groups"}},
+	}
+	for _, tt := range tests {
+		m := received[tt.address]
+		if m.From != "noreply@example.com" || !reflect.DeepEqual(m.To, []string{tt.address}) {
+			t.Errorf("%s: envelope = %s -> %v", tt.address, m.From, m.To)
+		}
+		parts := mailParts(t, m.Data)
+		text, html := parts["text/plain; charset=UTF-8"], parts["text/html; charset=UTF-8"]
+		if !strings.Contains(text, "Warnings:") || !strings.Contains(text, "Bye") {
+			t.Errorf("%s: text part doesn't have the prefix and suffix:\n%s", tt.address, text)
+		}
+		if !strings.Contains(html, "<table") {
+			t.Errorf("%s: HTML part doesn't have the table of findings:\n%s", tt.address, html)
+		}
+		for _, s := range tt.want {
+			if !strings.Contains(text, s) || !strings.Contains(html, s) {
+				t.Errorf("%s: %q is not in the text and HTML parts", tt.address, s)
+			}
+		}
+		for _, s := range tt.notWant {
+			if strings.Contains(text, s) || strings.Contains(html, s) {
+				t.Errorf("%s: %q is in the mail", tt.address, s)
+			}
+		}
+	}
+}
+
+func TestEmailNotifierStartTLSRequired(t *testing.T) {
+	server := newFakeSMTP(t)
+	e := &emailNotifier{Cfg: SMTP{Host: "127.0.0.1", Port: server.port(), StartTLS: true, From: "noreply@example.com", To: []string{"security@example.com"}}}
+	err := e.Notify(testEmailNotification())
+	if err == nil || !strings.Contains(err.Error(), "STARTTLS") {
+		t.Errorf("error = %v, want STARTTLS not supported", err)
+	}
+	if received := server.received(); len(received) != 0 {
+		t.Errorf("mails are sent without STARTTLS: %v", received)
+	}
+}
+
+func TestEmailNotifierContinuesAfterFailure(t *testing.T) {
+	server := newFakeSMTP(t, "db@example.com")
+	e := &emailNotifier{Cfg: SMTP{
+		Host:       "127.0.0.1",
+		Port:       server.port(),
+		From:       "noreply@example.com",
+		To:         []string{"security@example.com"},
+		Recipients: map[string][]string{"db": {"db@example.com"}, "web-prod": {"web@example.com"}},
+	}}
+	err := e.Notify(testEmailNotification())
+	if err == nil || !strings.Contains(err.Error(), "db@example.com") {
+		t.Errorf("error = %v, want the failure of db@example.com", err)
+	}
+	received := server.received()
+	for _, address := range []string{"security@example.com", "web@example.com"} {
+		if _, ok := received[address]; !ok {
+			t.Errorf("%s doesn't receive a mail after the failure of db@example.com", address)
+		}
+	}
+	if _, ok := received["db@example.com"]; ok {
+		t.Error("db@example.com receives a mail")
+	}
+}
+
+func TestEmailNotifierOneMailPerRun(t *testing.T) {
+	server := newFakeSMTP(t)
+	webhook := &countingNotifier{}
+	checker := newTestChecker()
+	checker.Notifiers = []Notifier{
+		webhook,
+		&emailNotifier{Cfg: SMTP{Host: "127.0.0.1", Port: server.port(), From: "noreply@example.com", To: []string{"security@example.com"}}},
+	}
+
+	checker.pending = []Notification{}
+	world := testEmailNotification()
+	egress := Notification{Prefix: "Egress:", Suffix: "Egress bye", Findings: []Finding{
+		{CheckID: CheckUnrestrictedEgress, Severity: SeverityMedium, ProjectID: "p-pay", Project: "payment", ResourceID: "sg-pay", ResourceName: "pay-sg"},
+	}}
+	for _, n := range []Notification{world, egress} {
+		if err := checker.notify(n); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if webhook.count != 2 {
+		t.Errorf("webhook is notified %d times, want 2", webhook.count)
+	}
+	if received := server.received(); len(received) != 0 {
+		t.Fatalf("mails are sent before the end of the run: %v", received)
+	}
+
+	if err := checker.flushNotifications(); err != nil {
+		t.Fatal(err)
+	}
+	mails := server.all()
+	if len(mails) != 1 {
+		t.Fatalf("%d mails are sent, want 1", len(mails))
+	}
+	text := mailParts(t, mails[0].Data)["text/plain; charset=UTF-8"]
+	for _, s := range []string{"Warnings:", "web-sg", "Bye", "Egress:", "pay-sg", "Egress bye"} {
+		if !strings.Contains(text, s) {
+			t.Errorf("%q is not in the mail:\n%s", s, text)
+		}
+	}
+	if checker.pending != nil {
+		t.Errorf("pending = %v after the flush", checker.pending)
+	}
+
+	// Notifications out of a run, e.g. the hygiene digest, are sent at once.
+	if err := checker.notify(world); err != nil {
+		t.Fatal(err)
+	}
+	if mails := server.all(); len(mails) != 2 {
+		t.Errorf("%d mails are sent, want 2", len(mails))
+	}
+}
+
+// countingNotifier counts the notifications, like a notifier that is not batched.
+type countingNotifier struct {
+	count int
+}
+
+func (c *countingNotifier) Notify(n Notification) error {
+	c.count++
+	return nil
+}
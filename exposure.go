@@ -0,0 +1,242 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/layer3/floatingips"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/security/groups"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/security/rules"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli"
+)
+
+// openService is a protocol and the ports of it that are reachable from public addresses.
+type openService struct {
+	Protocol string   `json:"protocol"`
+	Ports    string   `json:"ports"`
+	Sources  []string `json:"sources"`
+}
+
+// addressExposure is the effective exposure of a public address of a port,
+// i.e. the union of the ingress rules of all security groups attached to the port.
+type addressExposure struct {
+	Tenant   string        `json:"tenant"`
+	PortID   string        `json:"port_id"`
+	DeviceID string        `json:"device_id"`
+	Address  string        `json:"address"`
+	Type     string        `json:"type"`
+	Open     []openService `json:"open"`
+}
+
+type publicAddress struct {
+	Address string
+	Type    string
+}
+
+func StartExposure(c *cli.Context) error {
+	cfg, err := ReadConfig(c.String("config"), true)
+	if err != nil {
+		return err
+	}
+
+	checker := NewOpenStackChecker(cfg, nil)
+	inv, err := checker.fetchInventory()
+	if err != nil {
+		return errors.Wrap(err, "Failed to fetch resources")
+	}
+	exposures, err := checker.exposureMap(inv)
+	if err != nil {
+		return errors.Wrap(err, "Failed to analyze exposure")
+	}
+
+	switch c.String("format") {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(exposures)
+	case "table":
+		w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(w, "TENANT\tPORT\tADDRESS\tTYPE\tPROTOCOL\tPORTS\tSOURCES")
+		for _, e := range exposures {
+			for _, s := range e.Open {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", e.Tenant, e.PortID, e.Address, e.Type, s.Protocol, s.Ports, strings.Join(s.Sources, ","))
+			}
+		}
+		return w.Flush()
+	}
+	return fmt.Errorf("unknown format: %s", c.String("format"))
+}
+
+// publicAddresses returns floating IPs and public fixed IPs of the port.
+func (checker *OpenStackSecurityGroupChecker) publicAddresses(port neutronPort, fips []floatingips.FloatingIP) ([]publicAddress, error) {
+	addresses := []publicAddress{}
+	// FIPがバインドされているならば通知対象にする
+	for _, fip := range fips {
+		if fip.PortID == port.ID {
+			addresses = append(addresses, publicAddress{Address: fip.FloatingIP, Type: "floating"})
+		}
+	}
+	if contain(checker.Cfg.InternalNetworks, port.NetworkID) || contain(checker.internalNetworkIDs, port.NetworkID) {
+		return addresses, nil
+	}
+	external := contain(checker.Cfg.ExternalNetworks, port.NetworkID) || contain(checker.externalNetworkIDs, port.NetworkID)
+	// パブリックIPを持つポートならば通知対象にする
+	for _, ip := range port.FixedIPs {
+		isPrivate, err := isPrivateIP(net.ParseIP(ip.IPAddress), checker.Cfg.PrivateRanges)
+		if err != nil {
+			return nil, err
+		}
+		if external || !isPrivate {
+			addresses = append(addresses, publicAddress{Address: ip.IPAddress, Type: "fixed"})
+		}
+	}
+	return addresses, nil
+}
+
+// addressEtherType returns "IPv6" for an IPv6 address and "IPv4" otherwise.
+func addressEtherType(address string) string {
+	if ip := net.ParseIP(address); ip != nil && ip.To4() == nil {
+		return "IPv6"
+	}
+	return "IPv4"
+}
+
+// ruleEtherType returns the address family of the rule, from its ethertype or else its remote
+// prefix. It is empty if the family is unknown, e.g. for a remote group without ethertype.
+func ruleEtherType(rule rules.SecGroupRule) string {
+	if rule.EtherType != "" {
+		return rule.EtherType
+	}
+	source := effectiveSource(rule)
+	if source.RemoteGroupID != "" {
+		return ""
+	}
+	ip, _, err := net.ParseCIDR(source.Prefix)
+	if err != nil {
+		return ""
+	}
+	return addressEtherType(ip.String())
+}
+
+// publicPortsOf returns IDs and public addresses of the public ports the security group is attached
+// to. When etherType is set, only addresses of the family ("IPv4" or "IPv6") are counted.
+func (checker *OpenStackSecurityGroupChecker) publicPortsOf(sg groups.SecGroup, ports []neutronPort, fips []floatingips.FloatingIP, etherType string) ([]string, []string, error) {
+	ids := []string{}
+	ips := []string{}
+	for _, port := range ports {
+		if !contain(port.SecurityGroups, sg.ID) {
+			continue
+		}
+		addresses, err := checker.publicAddresses(port, fips)
+		if err != nil {
+			return nil, nil, err
+		}
+		matched := []string{}
+		for _, address := range addresses {
+			if etherType == "" || addressEtherType(address.Address) == etherType {
+				matched = append(matched, address.Address)
+			}
+		}
+		if len(matched) == 0 {
+			continue
+		}
+		ids = append(ids, port.ID)
+		ips = append(ips, matched...)
+	}
+	return ids, ips, nil
+}
+
+// effectiveExposure computes the protocols and ports of an address that are reachable from public
+// addresses through the security groups attached to the port.
+func (checker *OpenStackSecurityGroupChecker) effectiveExposure(port neutronPort, address string, securityGroups []groups.SecGroup) ([]openService, error) {
+	if port.PortSecurityEnabled != nil && !*port.PortSecurityEnabled {
+		return []openService{{Protocol: "any", Ports: "*", Sources: []string{"port security disabled"}}}, nil
+	}
+
+	etherType := addressEtherType(address)
+
+	ranges := map[string]portRanges{}
+	sources := map[string][]string{}
+	for _, sg := range securityGroups {
+		if !contain(port.SecurityGroups, sg.ID) {
+			continue
+		}
+		for _, rule := range sg.Rules {
+			if rule.Direction != "ingress" || (rule.EtherType != "" && rule.EtherType != etherType) {
+				continue
+			}
+			source := effectiveSource(rule)
+			if source.RemoteGroupID != "" {
+				continue
+			}
+			_, prefix, err := net.ParseCIDR(source.Prefix)
+			if err != nil {
+				continue
+			}
+			count, err := publicAddressCount(prefix, checker.Cfg.PrivateRanges)
+			if err != nil {
+				return nil, err
+			}
+			if count.Sign() == 0 {
+				continue
+			}
+
+			protocol := normalizeProtocol(rule.Protocol)
+			if protocolHasPorts(protocol) {
+				ranges[protocol] = append(ranges[protocol], rulePortRange(rule))
+			} else if _, ok := ranges[protocol]; !ok {
+				ranges[protocol] = portRanges{}
+			}
+			if !contain(sources[protocol], source.Prefix) {
+				sources[protocol] = append(sources[protocol], source.Prefix)
+			}
+		}
+	}
+
+	services := []openService{}
+	for protocol, rs := range ranges {
+		ports := "*"
+		if protocolHasPorts(protocol) {
+			ports = rs.normalize().String()
+		}
+		services = append(services, openService{Protocol: protocol, Ports: ports, Sources: sources[protocol]})
+	}
+	sort.Slice(services, func(i, j int) bool { return services[i].Protocol < services[j].Protocol })
+	return services, nil
+}
+
+// exposureMap computes the effective exposure of every public address of every port.
+func (checker *OpenStackSecurityGroupChecker) exposureMap(inv *inventory) ([]addressExposure, error) {
+	exposures := []addressExposure{}
+	for _, port := range inv.Ports {
+		addresses, err := checker.publicAddresses(port, inv.FloatingIPs)
+		if err != nil {
+			return nil, err
+		}
+		projectName, err := getProjectNameFromID(port.TenantID, checker.Projects)
+		if err != nil {
+			projectName = port.TenantID
+		}
+		for _, address := range addresses {
+			services, err := checker.effectiveExposure(port, address.Address, inv.SecurityGroups)
+			if err != nil {
+				return nil, err
+			}
+			exposures = append(exposures, addressExposure{
+				Tenant:   projectName,
+				PortID:   port.ID,
+				DeviceID: port.DeviceID,
+				Address:  address.Address,
+				Type:     address.Type,
+				Open:     services,
+			})
+		}
+	}
+	return exposures, nil
+}
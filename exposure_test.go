@@ -0,0 +1,112 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/layer3/floatingips"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/security/groups"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/security/rules"
+)
+
+func TestIsFullOpenAddressFamily(t *testing.T) {
+	sg := groups.SecGroup{ID: "sg-web", Name: "web", Rules: []rules.SecGroupRule{
+		ingress("v4", "IPv4", "tcp", 443, 443, "0.0.0.0/0"),
+		ingress("v6", "IPv6", "tcp", 443, 443, "::/0"),
+		// Neither ethertype nor prefix: any IPv4 address.
+		{ID: "implicit", Direction: "ingress", Protocol: "tcp", PortRangeMin: 80, PortRangeMax: 80},
+	}}
+	fips := []floatingips.FloatingIP{{FloatingIP: "203.0.113.10", PortID: "port-v4"}}
+
+	tests := []struct {
+		name  string
+		ports []neutronPort
+		want  map[string][]string
+	}{
+		{
+			name:  "IPv4 floating IP only",
+			ports: []neutronPort{testPort("port-v4", "10.0.0.1", "sg-web")},
+			want:  map[string][]string{"v4": {"203.0.113.10"}, "implicit": {"203.0.113.10"}},
+		},
+		{
+			name:  "public IPv6 only",
+			ports: []neutronPort{testPort("port-v6", "2600::1", "sg-web")},
+			want:  map[string][]string{"v6": {"2600::1"}},
+		},
+		{
+			name:  "one port of each family",
+			ports: []neutronPort{testPort("port-v4", "10.0.0.1", "sg-web"), testPort("port-v6", "2600::1", "sg-web")},
+			want:  map[string][]string{"v4": {"203.0.113.10"}, "v6": {"2600::1"}, "implicit": {"203.0.113.10"}},
+		},
+	}
+	for _, tt := range tests {
+		findings, err := newTestChecker().isFullOpen(sg, tt.ports, fips, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := map[string][]string{}
+		for _, f := range findings {
+			for _, r := range sg.Rules {
+				if newFindingRule(r).Remote == f.Rule.Remote && newFindingRule(r).PortRange == f.Rule.PortRange {
+					got[r.ID] = f.IPs
+				}
+			}
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: findings = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRuleEtherType(t *testing.T) {
+	tests := []struct {
+		rule rules.SecGroupRule
+		want string
+	}{
+		{rules.SecGroupRule{EtherType: "IPv6", RemoteIPPrefix: "::/0"}, "IPv6"},
+		{rules.SecGroupRule{RemoteIPPrefix: "::/0"}, "IPv6"},
+		{rules.SecGroupRule{RemoteIPPrefix: "0.0.0.0/0"}, "IPv4"},
+		{rules.SecGroupRule{}, "IPv4"},
+		{rules.SecGroupRule{RemoteGroupID: "sg-lb"}, ""},
+	}
+	for _, tt := range tests {
+		if got := ruleEtherType(tt.rule); got != tt.want {
+			t.Errorf("ruleEtherType(%+v) = %q, want %q", tt.rule, got, tt.want)
+		}
+	}
+}
+
+func TestIsFullOpenPublicAddressesDetail(t *testing.T) {
+	sg := groups.SecGroup{ID: "sg-web", Name: "web", Rules: []rules.SecGroupRule{
+		ingress("world", "IPv4", "tcp", 443, 443, "0.0.0.0/0"),
+	}}
+	ports := []neutronPort{testPort("port-web", "10.0.0.1", "sg-web")}
+	fips := []floatingips.FloatingIP{{FloatingIP: "203.0.113.10", PortID: "port-web"}}
+
+	tests := []struct {
+		name                string
+		maxPublicPrefixSize int
+		want                bool
+	}{
+		{"threshold disabled", 0, false},
+		{"threshold exceeded", 8, true},
+	}
+	for _, tt := range tests {
+		checker := newTestChecker()
+		checker.Cfg.MaxPublicPrefixSize = tt.maxPublicPrefixSize
+		findings, err := checker.isFullOpen(sg, ports, fips, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(findings) != 1 {
+			t.Fatalf("%s: got %d findings, want 1", tt.name, len(findings))
+		}
+		got := false
+		for _, d := range findings[0].Details {
+			got = got || d.Title == "PublicAddresses"
+		}
+		if got != tt.want {
+			t.Errorf("%s: PublicAddresses detail = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
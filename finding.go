@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/security/rules"
+	"github.com/slack-go/slack"
+)
+
+const FIRST_SEEN_REDIS_KEY = "finding_first_seen"
+
+type Severity string
+
+const (
+	SeverityHigh   Severity = "high"
+	SeverityMedium Severity = "medium"
+	SeverityLow    Severity = "low"
+)
+
+// Check IDs of findings.
+const (
+	CheckWorldOpen            = "world-open-ingress"
+	CheckTransitiveExposure   = "transitive-exposure"
+	CheckPortSecurityDisabled = "port-security-disabled"
+	CheckAddressPairs         = "broad-address-pairs"
+	CheckLoadBalancer         = "exposed-load-balancer"
+	CheckUnrestrictedEgress   = "unrestricted-egress"
+	CheckPolicy               = "policy"
+)
+
+// Resource types of findings.
+const (
+	ResourceSecurityGroup = "security_group"
+	ResourcePort          = "port"
+	ResourceLoadBalancer  = "load_balancer"
+)
+
+// Finding is a problem found by a check. Outputs such as Slack and stdout are rendered from it.
+type Finding struct {
+	CheckID      string          `json:"check_id"`
+	Severity     Severity        `json:"severity"`
+	ProjectID    string          `json:"project_id"`
+	Project      string          `json:"project"`
+	ResourceType string          `json:"resource_type"`
+	ResourceID   string          `json:"resource_id"`
+	ResourceName string          `json:"resource_name"`
+	Rule         *FindingRule    `json:"rule,omitempty"`
+	Ports        []string        `json:"ports,omitempty"`
+	IPs          []string        `json:"ips,omitempty"`
+	Details      []FindingDetail `json:"details,omitempty"`
+	FirstSeen    time.Time       `json:"first_seen"`
+}
+
+// FindingRule is the security group rule a finding is about.
+type FindingRule struct {
+	Direction string `json:"direction"`
+	Protocol  string `json:"protocol"`
+	PortRange string `json:"port_range"`
+	Remote    string `json:"remote"`
+}
+
+// FindingDetail is additional check specific information.
+type FindingDetail struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+	Short bool   `json:"short,omitempty"`
+}
+
+func newFindingRule(rule rules.SecGroupRule) *FindingRule {
+	return &FindingRule{
+		Direction: rule.Direction,
+		Protocol:  normalizeProtocol(rule.Protocol),
+		PortRange: formatPortRange(rule),
+		Remote:    effectiveSource(rule).String(),
+	}
+}
+
+func (checker *OpenStackSecurityGroupChecker) newFinding(checkID string, severity Severity, projectID string, resourceType string, resourceID string, resourceName string) Finding {
+	projectName, err := getProjectNameFromID(projectID, checker.Projects)
+	if err != nil {
+		projectName = projectID
+	}
+	return Finding{
+		CheckID:      checkID,
+		Severity:     severity,
+		ProjectID:    projectID,
+		Project:      projectName,
+		ResourceType: resourceType,
+		ResourceID:   resourceID,
+		ResourceName: resourceName,
+	}
+}
+
+func (f *Finding) addDetail(title string, value string) {
+	f.Details = append(f.Details, FindingDetail{Title: title, Value: value})
+}
+
+func (f *Finding) addShortDetail(title string, value string) {
+	f.Details = append(f.Details, FindingDetail{Title: title, Value: value, Short: true})
+}
+
+// Fingerprint identifies the same finding across runs.
+func (f Finding) Fingerprint() string {
+	items := []string{f.CheckID, f.ResourceID}
+	if f.Rule != nil {
+		items = append(items, f.Rule.Direction, f.Rule.Protocol, f.Rule.PortRange, f.Rule.Remote)
+	}
+	return strings.Join(items, "|")
+}
+
+func (s Severity) color() string {
+	switch s {
+	case SeverityHigh:
+		return "#ff6347"
+	case SeverityMedium:
+		return "#ffa500"
+	}
+	return "#daa520"
+}
+
+// Attachment renders the finding as a Slack attachment. The "ID" field is used by the server to
+// allow the resource temporarily when a reaction is added.
+func (f Finding) Attachment() slack.Attachment {
+	fields := []slack.AttachmentField{
+		{Title: "Tenant", Value: f.Project},
+		{Title: "ID", Value: f.ResourceID},
+		{Title: "Name", Value: f.ResourceName},
+	}
+	if f.Rule != nil {
+		remote := "Source"
+		if f.Rule.Direction == "egress" {
+			remote = "Destination"
+		}
+		fields = append(fields,
+			slack.AttachmentField{Title: "Protocol", Value: f.Rule.Protocol},
+			slack.AttachmentField{Title: "PortRange", Value: f.Rule.PortRange},
+			slack.AttachmentField{Title: remote, Value: f.Rule.Remote},
+		)
+	}
+	if len(f.IPs) > 0 {
+		fields = append(fields, slack.AttachmentField{Title: "IPs", Value: strings.Join(f.IPs, "\n")})
+	}
+	for _, d := range f.Details {
+		fields = append(fields, slack.AttachmentField{Title: d.Title, Value: d.Value, Short: d.Short})
+	}
+	return slack.Attachment{
+		Color:  f.Severity.color(),
+		Fields: fields,
+	}
+}
+
+// Print writes an allow rule for the finding to stdout so that it can be pasted into the config.
+func (f Finding) Print() {
+	if f.ResourceType != ResourceSecurityGroup {
+		return
+	}
+	fmt.Printf("[[rules]]\n")
+	fmt.Printf("tenant = \"%s\"\n", f.Project)
+	fmt.Printf("sg = \"%s\"\n", f.ResourceName)
+	for _, d := range f.Details {
+		if d.Title == "Created" {
+			fmt.Printf("created = \"%s\"\n", d.Value)
+		}
+	}
+	if f.Rule != nil {
+		if f.Rule.Direction == "egress" {
+			fmt.Printf("direction = \"egress\"\n")
+		}
+		if protocolHasPorts(f.Rule.Protocol) {
+			fmt.Printf("port = [\"%s/%s\"]\n", f.Rule.Protocol, f.Rule.PortRange)
+		} else {
+			fmt.Printf("port = [\"%s\"]\n", f.Rule.Protocol)
+		}
+	}
+}
+
+// recordFirstSeen sets FirstSeen of the findings, remembering the first time each of them was
+// found in Redis. In dry run, new findings are not remembered and are first seen now.
+func (checker *OpenStackSecurityGroupChecker) recordFirstSeen(ctx context.Context, redisClient *redis.Client, findings []Finding) ([]Finding, error) {
+	now := time.Now()
+	for i, f := range findings {
+		if !checker.Cfg.DryRun {
+			_, err := redisClient.HSetNX(ctx, FIRST_SEEN_REDIS_KEY, f.Fingerprint(), now.Unix()).Result()
+			if err != nil {
+				return nil, err
+			}
+		}
+		v, err := redisClient.HGet(ctx, FIRST_SEEN_REDIS_KEY, f.Fingerprint()).Result()
+		if err == redis.Nil {
+			v = ""
+		} else if err != nil {
+			return nil, err
+		}
+		sec, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			sec = now.Unix()
+		}
+		findings[i].FirstSeen = time.Unix(sec, 0)
+	}
+	return findings, nil
+}
+
+// pruneFirstSeen forgets the findings that are not found in the current run, so that a finding
+// found again later is first seen then. Nothing is forgotten in dry run.
+func (checker *OpenStackSecurityGroupChecker) pruneFirstSeen(ctx context.Context, redisClient *redis.Client, findings []Finding) error {
+	if checker.Cfg.DryRun {
+		return nil
+	}
+	current := map[string]bool{}
+	for _, f := range findings {
+		current[f.Fingerprint()] = true
+	}
+	fingerprints, err := redisClient.HKeys(ctx, FIRST_SEEN_REDIS_KEY).Result()
+	if err != nil {
+		return err
+	}
+	stale := []string{}
+	for _, fingerprint := range fingerprints {
+		if !current[fingerprint] {
+			stale = append(stale, fingerprint)
+		}
+	}
+	if len(stale) == 0 {
+		return nil
+	}
+	return redisClient.HDel(ctx, FIRST_SEEN_REDIS_KEY, stale...).Err()
+}
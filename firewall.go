@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/security/rules"
+)
+
+// firewallRule is an allow or deny rule of a cloud whose rules are evaluated in priority order,
+// such as GCP firewall rules and Azure network security groups. Neutron rules can only allow, so
+// allow rules are converted after removing what is denied by rules of higher priority.
+type firewallRule struct {
+	// Priority is evaluated in ascending order. A deny rule wins over an allow rule of the same priority.
+	Priority  int
+	Deny      bool
+	Direction string
+	// Protocol is a canonical protocol name. Empty matches every protocol and has no ports.
+	Protocol string
+	// Ports are the matched ports. Empty means all ports.
+	Ports portRanges
+	// Remotes are source CIDRs of ingress rules or destination CIDRs of egress rules.
+	Remotes []string
+	// RemoteGroups are groups of instances, e.g. network tags or application security groups.
+	RemoteGroups []string
+	// Targets are IDs of the ports the rule applies to. AllTargets applies it to every port.
+	Targets     []string
+	AllTargets  bool
+	Description string
+}
+
+// firewallProtocol converts a protocol of GCP or Azure to a canonical name, where any protocol is empty.
+func firewallProtocol(protocol string) string {
+	switch strings.ToLower(protocol) {
+	case "", "*", "all", "any", "-1":
+		return ""
+	}
+	return normalizeProtocol(protocol)
+}
+
+// firewallRemote converts an address prefix or address to a CIDR. Other values are not addresses.
+func firewallRemote(prefix string) (string, bool) {
+	if _, _, err := net.ParseCIDR(prefix); err == nil {
+		return prefix, true
+	}
+	ip := net.ParseIP(prefix)
+	if ip == nil {
+		return "", false
+	}
+	if ip.To4() != nil {
+		return fmt.Sprintf("%s/32", prefix), true
+	}
+	return fmt.Sprintf("%s/128", prefix), true
+}
+
+// deniedBy returns true if the deny rule d applies to all of the targets and remote of the allow rule r.
+func (r firewallRule) deniedBy(d firewallRule, remote string, isGroup bool) bool {
+	if !d.Deny || d.Direction != r.Direction || d.Priority > r.Priority {
+		return false
+	}
+	if d.Protocol != "" && d.Protocol != r.Protocol {
+		return false
+	}
+	if !d.AllTargets {
+		if r.AllTargets || len(r.Targets) == 0 {
+			return false
+		}
+		for _, target := range r.Targets {
+			if !contain(d.Targets, target) {
+				return false
+			}
+		}
+	}
+	if isGroup {
+		return contain(d.RemoteGroups, remote)
+	}
+	_, inner, err := net.ParseCIDR(remote)
+	if err != nil {
+		return false
+	}
+	for _, cidr := range d.Remotes {
+		_, outer, err := net.ParseCIDR(cidr)
+		if err == nil && prefixContains(outer, inner) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowedRules converts the allow rule r to Neutron rules, one per remote and port range, without
+// the ports denied by the deny rules in all.
+func (r firewallRule) allowedRules(all []firewallRule) []rules.SecGroupRule {
+	results := []rules.SecGroupRule{}
+	if r.Deny {
+		return results
+	}
+	convert := func(remote string, isGroup bool) {
+		ports := r.Ports
+		if len(ports) == 0 && protocolHasPorts(r.Protocol) {
+			ports = portRanges{{Min: minPort, Max: maxPort}}
+		}
+		for _, d := range all {
+			if !r.deniedBy(d, remote, isGroup) {
+				continue
+			}
+			if len(d.Ports) == 0 {
+				return
+			}
+			if len(ports) == 0 {
+				continue
+			}
+			remaining := portRanges{}
+			for _, p := range ports {
+				remaining = append(remaining, d.Ports.uncovered(p)...)
+			}
+			if len(remaining) == 0 {
+				return
+			}
+			ports = remaining
+		}
+
+		base := rules.SecGroupRule{
+			Direction:   r.Direction,
+			EtherType:   "IPv4",
+			Protocol:    r.Protocol,
+			Description: r.Description,
+		}
+		if isGroup {
+			base.RemoteGroupID = remote
+		} else {
+			base.RemoteIPPrefix = remote
+			if strings.Contains(remote, ":") {
+				base.EtherType = "IPv6"
+			}
+		}
+		if len(ports) == 0 {
+			results = append(results, base)
+			return
+		}
+		for _, p := range ports {
+			rule := base
+			rule.PortRangeMin, rule.PortRangeMax = p.Min, p.Max
+			results = append(results, rule)
+		}
+	}
+	for _, remote := range r.Remotes {
+		convert(remote, false)
+	}
+	for _, group := range r.RemoteGroups {
+		convert(group, true)
+	}
+	return results
+}
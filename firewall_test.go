@@ -0,0 +1,390 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/services/network/mgmt/2020-06-01/network"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/security/rules"
+	"google.golang.org/api/compute/v1"
+)
+
+// formatRules renders rules as "direction protocol min-max remote" to compare them in tests.
+func formatRules(rs []rules.SecGroupRule) []string {
+	results := []string{}
+	for _, r := range rs {
+		remote := r.RemoteIPPrefix
+		if r.RemoteGroupID != "" {
+			remote = r.RemoteGroupID
+		}
+		results = append(results, fmt.Sprintf("%s %s %d-%d %s", r.Direction, r.Protocol, r.PortRangeMin, r.PortRangeMax, remote))
+	}
+	return results
+}
+
+func TestAllowedRules(t *testing.T) {
+	allow := firewallRule{Priority: 1000, Direction: "ingress", Protocol: "tcp", Ports: portRanges{{Min: 1000, Max: 2000}}, Remotes: []string{"0.0.0.0/0"}, AllTargets: true}
+	deny := firewallRule{Priority: 500, Deny: true, Direction: "ingress", Protocol: "tcp", Remotes: []string{"0.0.0.0/0"}, AllTargets: true}
+	with := func(r firewallRule, f func(r *firewallRule)) firewallRule {
+		f(&r)
+		return r
+	}
+
+	tests := []struct {
+		name  string
+		allow firewallRule
+		deny  []firewallRule
+		want  []string
+	}{
+		{
+			name:  "no deny",
+			allow: allow,
+			want:  []string{"ingress tcp 1000-2000 0.0.0.0/0"},
+		},
+		{
+			name:  "deny of higher priority removes all ports",
+			allow: allow,
+			deny:  []firewallRule{deny},
+			want:  []string{},
+		},
+		{
+			name:  "deny of lower priority is evaluated after the allow",
+			allow: allow,
+			deny:  []firewallRule{with(deny, func(r *firewallRule) { r.Priority = 2000 })},
+			want:  []string{"ingress tcp 1000-2000 0.0.0.0/0"},
+		},
+		{
+			name:  "deny wins at the same priority",
+			allow: allow,
+			deny:  []firewallRule{with(deny, func(r *firewallRule) { r.Priority = 1000 })},
+			want:  []string{},
+		},
+		{
+			name:  "partial port overlap splits the range",
+			allow: allow,
+			deny:  []firewallRule{with(deny, func(r *firewallRule) { r.Ports = portRanges{{Min: 1500, Max: 1600}} })},
+			want:  []string{"ingress tcp 1000-1499 0.0.0.0/0", "ingress tcp 1601-2000 0.0.0.0/0"},
+		},
+		{
+			name:  "overlap at both ends",
+			allow: allow,
+			deny: []firewallRule{
+				with(deny, func(r *firewallRule) { r.Ports = portRanges{{Min: 900, Max: 1100}} }),
+				with(deny, func(r *firewallRule) { r.Ports = portRanges{{Min: 1900, Max: 2100}} }),
+			},
+			want: []string{"ingress tcp 1101-1899 0.0.0.0/0"},
+		},
+		{
+			name:  "deny of other ports",
+			allow: allow,
+			deny:  []firewallRule{with(deny, func(r *firewallRule) { r.Ports = portRanges{{Min: 22, Max: 22}} })},
+			want:  []string{"ingress tcp 1000-2000 0.0.0.0/0"},
+		},
+		{
+			name:  "deny of all protocols",
+			allow: allow,
+			deny:  []firewallRule{with(deny, func(r *firewallRule) { r.Protocol = "" })},
+			want:  []string{},
+		},
+		{
+			name:  "deny of another protocol",
+			allow: allow,
+			deny:  []firewallRule{with(deny, func(r *firewallRule) { r.Protocol = "udp" })},
+			want:  []string{"ingress tcp 1000-2000 0.0.0.0/0"},
+		},
+		{
+			name:  "deny of another direction",
+			allow: allow,
+			deny:  []firewallRule{with(deny, func(r *firewallRule) { r.Direction = "egress" })},
+			want:  []string{"ingress tcp 1000-2000 0.0.0.0/0"},
+		},
+		{
+			name:  "deny of part of the remote keeps the rule",
+			allow: allow,
+			deny:  []firewallRule{with(deny, func(r *firewallRule) { r.Remotes = []string{"10.0.0.0/8"} })},
+			want:  []string{"ingress tcp 1000-2000 0.0.0.0/0"},
+		},
+		{
+			name:  "deny per remote",
+			allow: with(allow, func(r *firewallRule) { r.Remotes = []string{"10.1.0.0/16", "203.0.113.0/24"} }),
+			deny:  []firewallRule{with(deny, func(r *firewallRule) { r.Remotes = []string{"10.0.0.0/8"} })},
+			want:  []string{"ingress tcp 1000-2000 203.0.113.0/24"},
+		},
+		{
+			name:  "all ports of a protocol",
+			allow: with(allow, func(r *firewallRule) { r.Ports = nil }),
+			deny:  []firewallRule{with(deny, func(r *firewallRule) { r.Ports = portRanges{{Min: 22, Max: 22}} })},
+			want:  []string{"ingress tcp 1-21 0.0.0.0/0", "ingress tcp 23-65535 0.0.0.0/0"},
+		},
+		{
+			name:  "any protocol is not split by a deny of ports",
+			allow: with(allow, func(r *firewallRule) { r.Protocol, r.Ports = "", nil }),
+			deny:  []firewallRule{with(deny, func(r *firewallRule) { r.Protocol, r.Ports = "", portRanges{{Min: 22, Max: 22}} })},
+			want:  []string{"ingress  0-0 0.0.0.0/0"},
+		},
+		{
+			name:  "deny of a subset of the targets",
+			allow: with(allow, func(r *firewallRule) { r.AllTargets, r.Targets = false, []string{"a", "b"} }),
+			deny:  []firewallRule{with(deny, func(r *firewallRule) { r.AllTargets, r.Targets = false, []string{"a"} })},
+			want:  []string{"ingress tcp 1000-2000 0.0.0.0/0"},
+		},
+		{
+			name:  "deny of all the targets",
+			allow: with(allow, func(r *firewallRule) { r.AllTargets, r.Targets = false, []string{"a", "b"} }),
+			deny:  []firewallRule{with(deny, func(r *firewallRule) { r.AllTargets, r.Targets = false, []string{"a", "b", "c"} })},
+			want:  []string{},
+		},
+		{
+			name:  "deny of some targets doesn't apply to every target",
+			allow: allow,
+			deny:  []firewallRule{with(deny, func(r *firewallRule) { r.AllTargets, r.Targets = false, []string{"a"} })},
+			want:  []string{"ingress tcp 1000-2000 0.0.0.0/0"},
+		},
+		{
+			name:  "deny of a remote group",
+			allow: with(allow, func(r *firewallRule) { r.Remotes, r.RemoteGroups = nil, []string{"tag:web", "tag:db"} }),
+			deny:  []firewallRule{with(deny, func(r *firewallRule) { r.Remotes, r.RemoteGroups = nil, []string{"tag:db"} })},
+			want:  []string{"ingress tcp 1000-2000 tag:web"},
+		},
+		{
+			name:  "IPv6 remote",
+			allow: with(allow, func(r *firewallRule) { r.Remotes = []string{"::/0"} }),
+			deny:  []firewallRule{deny},
+			want:  []string{"ingress tcp 1000-2000 ::/0"},
+		},
+		{
+			name:  "deny rule is not converted",
+			allow: deny,
+			want:  []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			all := append([]firewallRule{tt.allow}, tt.deny...)
+			got := formatRules(tt.allow.allowedRules(all))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("allowedRules() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func azureRule(name string, priority int32, access network.SecurityRuleAccess, direction network.SecurityRuleDirection, protocol network.SecurityRuleProtocol, source string, destination string, port string) network.SecurityRule {
+	return network.SecurityRule{
+		Name: to.StringPtr(name),
+		SecurityRulePropertiesFormat: &network.SecurityRulePropertiesFormat{
+			Priority:                 to.Int32Ptr(priority),
+			Access:                   access,
+			Direction:                direction,
+			Protocol:                 protocol,
+			SourceAddressPrefix:      to.StringPtr(source),
+			SourcePortRange:          to.StringPtr("*"),
+			DestinationAddressPrefix: to.StringPtr(destination),
+			DestinationPortRange:     to.StringPtr(port),
+		},
+	}
+}
+
+func TestAzureSecurityGroups(t *testing.T) {
+	allow, deny := network.SecurityRuleAccessAllow, network.SecurityRuleAccessDeny
+	in, out := network.SecurityRuleDirectionInbound, network.SecurityRuleDirectionOutbound
+	tcp, anyProtocol := network.SecurityRuleProtocolTCP, network.SecurityRuleProtocolAsterisk
+	nsg := network.SecurityGroup{
+		ID:   to.StringPtr("/nsg/web"),
+		Name: to.StringPtr("web"),
+		SecurityGroupPropertiesFormat: &network.SecurityGroupPropertiesFormat{
+			SecurityRules: &[]network.SecurityRule{
+				azureRule("https", 100, allow, in, tcp, "Internet", "*", "443"),
+				azureRule("deny-ssh", 200, deny, in, tcp, "*", "*", "22"),
+				azureRule("ssh", 300, allow, in, tcp, "*", "*", "22"),
+				azureRule("app", 400, allow, in, anyProtocol, "203.0.113.0/24", "*", "8000-8100"),
+				azureRule("deny-app-debug", 150, deny, in, anyProtocol, "203.0.113.0/24", "*", "8080"),
+			},
+			// The default rules of every network security group.
+			DefaultSecurityRules: &[]network.SecurityRule{
+				azureRule("AllowVnetInBound", 65000, allow, in, anyProtocol, "VirtualNetwork", "VirtualNetwork", "*"),
+				azureRule("AllowAzureLoadBalancerInBound", 65001, allow, in, anyProtocol, "AzureLoadBalancer", "*", "*"),
+				azureRule("DenyAllInBound", 65500, deny, in, anyProtocol, "*", "*", "*"),
+				azureRule("AllowVnetOutBound", 65000, allow, out, anyProtocol, "VirtualNetwork", "VirtualNetwork", "*"),
+				azureRule("AllowInternetOutBound", 65001, allow, out, anyProtocol, "*", "Internet", "*"),
+				azureRule("DenyAllOutBound", 65500, deny, out, anyProtocol, "*", "*", "*"),
+			},
+		},
+	}
+
+	webASG := azureRule("admin", 500, allow, in, tcp, "198.51.100.0/24", "", "3389")
+	webASG.DestinationAddressPrefix = nil
+	webASG.DestinationApplicationSecurityGroups = &[]network.ApplicationSecurityGroup{{ID: to.StringPtr("/asg/web")}}
+	*nsg.SecurityRules = append(*nsg.SecurityRules, webASG)
+	attached := []azureInterface{
+		{Port: testPort("web", "10.0.0.1"), ASGs: []string{"/asg/web"}},
+		{Port: testPort("db", "10.0.1.1"), ASGs: []string{"/asg/db"}},
+	}
+
+	sgs, targets := azureSecurityGroups("sub", nsg, attached)
+	got := map[string][]string{}
+	gotTargets := map[string][]string{}
+	for _, sg := range sgs {
+		got[sg.Name] = formatRules(sg.Rules)
+		gotTargets[sg.Name] = targets[sg.ID]
+		for i, r := range sg.Rules {
+			if r.ID != fmt.Sprintf("%s-%d", sg.ID, i) || r.SecGroupID != sg.ID || r.TenantID != "sub" {
+				t.Errorf("rule %d of %s = %+v", i, sg.Name, r)
+			}
+		}
+	}
+	// Deny rules don't become security groups. ssh is denied by deny-ssh, and 8080 of app by
+	// deny-app-debug for tcp and udp. DenyAllInBound and DenyAllOutBound are of the lowest
+	// priority and deny nothing allowed.
+	want := map[string][]string{
+		"web/https": {"ingress tcp 443-443 0.0.0.0/0", "ingress tcp 443-443 ::/0"},
+		"web/ssh":   {},
+		"web/app": {
+			"ingress tcp 8000-8079 203.0.113.0/24",
+			"ingress tcp 8081-8100 203.0.113.0/24",
+			"ingress udp 8000-8079 203.0.113.0/24",
+			"ingress udp 8081-8100 203.0.113.0/24",
+		},
+		"web/admin":                         {"ingress tcp 3389-3389 198.51.100.0/24"},
+		"web/AllowVnetInBound":              {"ingress  0-0 tag:VirtualNetwork"},
+		"web/AllowAzureLoadBalancerInBound": {"ingress  0-0 tag:AzureLoadBalancer"},
+		"web/AllowVnetOutBound":             {"egress  0-0 tag:VirtualNetwork"},
+		"web/AllowInternetOutBound":         {"egress  0-0 0.0.0.0/0", "egress  0-0 ::/0"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("rules =\n%q\nwant\n%q", got, want)
+	}
+
+	// The rule scoped by the application security group doesn't reach the db interface.
+	both := []string{"web", "db"}
+	wantTargets := map[string][]string{
+		"web/https":                         both,
+		"web/ssh":                           both,
+		"web/app":                           both,
+		"web/admin":                         {"web"},
+		"web/AllowVnetInBound":              both,
+		"web/AllowAzureLoadBalancerInBound": both,
+		"web/AllowVnetOutBound":             both,
+		"web/AllowInternetOutBound":         both,
+	}
+	if !reflect.DeepEqual(gotTargets, wantTargets) {
+		t.Errorf("targets = %v, want %v", gotTargets, wantTargets)
+	}
+	if sgs[0].ID != "/nsg/web/securityRules/https" {
+		t.Errorf("ID = %s, want /nsg/web/securityRules/https", sgs[0].ID)
+	}
+}
+
+func TestAzureAttachedInterfaces(t *testing.T) {
+	nsg := network.SecurityGroup{
+		ID: to.StringPtr("/NSG/web"),
+		SecurityGroupPropertiesFormat: &network.SecurityGroupPropertiesFormat{
+			Subnets: &[]network.Subnet{{ID: to.StringPtr("/Subnet/front")}},
+		},
+	}
+	interfaces := []azureInterface{
+		{Port: testPort("nic", "10.0.0.1"), NSG: "/nsg/web"},
+		{Port: testPort("subnet", "10.0.1.1"), Subnets: []string{"/subnet/front"}},
+		{Port: testPort("both", "10.0.1.2"), NSG: "/nsg/web", Subnets: []string{"/subnet/front"}},
+		{Port: testPort("other", "10.0.2.1"), NSG: "/nsg/db", Subnets: []string{"/subnet/back"}},
+	}
+	got := []string{}
+	for _, i := range azureAttachedInterfaces(nsg, interfaces) {
+		got = append(got, i.Port.ID)
+	}
+	if want := []string{"nic", "subnet", "both"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("azureAttachedInterfaces() = %v, want %v", got, want)
+	}
+}
+
+func TestAzureFirewallRulesASG(t *testing.T) {
+	attached := []azureInterface{
+		{Port: testPort("web-1", "10.0.0.1"), ASGs: []string{"/asg/web"}},
+		{Port: testPort("web-2", "10.0.0.2"), ASGs: []string{"/asg/web", "/asg/ops"}},
+		{Port: testPort("db-1", "10.0.1.1"), ASGs: []string{"/asg/db"}},
+	}
+	rule := azureRule("web-from-lb", 100, network.SecurityRuleAccessAllow, network.SecurityRuleDirectionInbound, network.SecurityRuleProtocolTCP, "", "", "443")
+	rule.SourceAddressPrefix = nil
+	rule.DestinationAddressPrefix = nil
+	rule.SourceApplicationSecurityGroups = &[]network.ApplicationSecurityGroup{{ID: to.StringPtr("/asg/lb")}}
+	rule.DestinationApplicationSecurityGroups = &[]network.ApplicationSecurityGroup{{ID: to.StringPtr("/ASG/web")}}
+
+	got := azureFirewallRules(rule, attached)
+	if len(got) != 1 {
+		t.Fatalf("got %d rules, want 1", len(got))
+	}
+	if got[0].AllTargets || !reflect.DeepEqual(got[0].Targets, []string{"web-1", "web-2"}) {
+		t.Errorf("targets = %v %v, want web-1 and web-2", got[0].AllTargets, got[0].Targets)
+	}
+	if !reflect.DeepEqual(got[0].RemoteGroups, []string{"/asg/lb"}) {
+		t.Errorf("RemoteGroups = %v, want [/asg/lb]", got[0].RemoteGroups)
+	}
+
+	// A destination prefix selects the attached interfaces by address.
+	rule = azureRule("db", 100, network.SecurityRuleAccessAllow, network.SecurityRuleDirectionInbound, network.SecurityRuleProtocolTCP, "10.0.0.0/24", "10.0.1.0/24", "5432")
+	got = azureFirewallRules(rule, attached)
+	if got[0].AllTargets || !reflect.DeepEqual(got[0].Targets, []string{"db-1"}) {
+		t.Errorf("targets = %v %v, want db-1", got[0].AllTargets, got[0].Targets)
+	}
+}
+
+func TestGCPTargets(t *testing.T) {
+	interfaces := []gcpInterface{
+		{Port: testPort("web", "10.0.0.1"), Network: "default", Tags: []string{"web"}, ServiceAccounts: []string{"web@example.iam"}},
+		{Port: testPort("db", "10.0.0.2"), Network: "default", Tags: []string{"db"}, ServiceAccounts: []string{"db@example.iam"}},
+		{Port: testPort("other", "10.1.0.1"), Network: "other", Tags: []string{"web"}},
+	}
+	tests := []struct {
+		name     string
+		firewall compute.Firewall
+		want     []string
+	}{
+		{"network", compute.Firewall{Network: "default"}, []string{"web", "db"}},
+		{"target tag", compute.Firewall{Network: "default", TargetTags: []string{"web"}}, []string{"web"}},
+		{"target service account", compute.Firewall{Network: "default", TargetServiceAccounts: []string{"db@example.iam"}}, []string{"db"}},
+		{"unknown tag", compute.Firewall{Network: "default", TargetTags: []string{"cache"}}, []string{}},
+	}
+	for _, tt := range tests {
+		if got := gcpTargets(&tt.firewall, interfaces); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: gcpTargets() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestGCPSecurityGroup(t *testing.T) {
+	firewalls := []*compute.Firewall{
+		{Id: 1, Name: "allow-web", Network: "default", Priority: 1000, Direction: "INGRESS", TargetTags: []string{"web"},
+			Allowed: []*compute.FirewallAllowed{{IPProtocol: "tcp", Ports: []string{"80", "443", "8000-9000"}}}},
+		{Id: 2, Name: "deny-debug", Network: "default", Priority: 900, Direction: "INGRESS", SourceRanges: []string{"0.0.0.0/0"},
+			Denied: []*compute.FirewallDenied{{IPProtocol: "tcp", Ports: []string{"8080"}}}},
+		{Id: 3, Name: "allow-db", Network: "default", Priority: 1000, Direction: "INGRESS", SourceTags: []string{"web"}, SourceServiceAccounts: []string{"app@example.iam"},
+			Allowed: []*compute.FirewallAllowed{{IPProtocol: "tcp", Ports: []string{"5432"}}}},
+	}
+	interfaces := []gcpInterface{
+		{Port: testPort("web", "10.0.0.1"), Network: "default", Tags: []string{"web"}},
+		{Port: testPort("db", "10.0.0.2"), Network: "default", Tags: []string{"db"}},
+	}
+	targets := map[string][]string{}
+	all := []firewallRule{}
+	for _, f := range firewalls {
+		targets[f.Name] = gcpTargets(f, interfaces)
+		all = append(all, gcpFirewallRules(f, targets[f.Name])...)
+	}
+
+	tests := []struct {
+		firewall *compute.Firewall
+		want     []string
+	}{
+		{firewalls[0], []string{"ingress tcp 80-80 0.0.0.0/0", "ingress tcp 443-443 0.0.0.0/0", "ingress tcp 8000-8079 0.0.0.0/0", "ingress tcp 8081-9000 0.0.0.0/0"}},
+		{firewalls[1], []string{}},
+		{firewalls[2], []string{"ingress tcp 5432-5432 tag:web", "ingress tcp 5432-5432 serviceAccount:app@example.iam"}},
+	}
+	for _, tt := range tests {
+		sg := gcpSecurityGroup("project", tt.firewall, targets[tt.firewall.Name], all)
+		if got := formatRules(sg.Rules); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: rules = %q, want %q", tt.firewall.Name, got, tt.want)
+		}
+	}
+}
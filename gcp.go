@@ -0,0 +1,287 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/servers"
+	"github.com/gophercloud/gophercloud/openstack/identity/v3/projects"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/layer3/floatingips"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/security/groups"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/networks"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/ports"
+	"github.com/pkg/errors"
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/option"
+)
+
+// gcpProvider fetches VPC firewall rules and instances. Each firewall rule becomes a security
+// group attached to the network interfaces of the instances it targets by network, tags or
+// service accounts. Deny rules are applied to the allow rules of lower priority.
+type gcpProvider struct {
+	Cfg GCP
+}
+
+// gcpInterface is a network interface of an instance, with the attributes firewall rules target.
+type gcpInterface struct {
+	Port            neutronPort
+	Network         string
+	Tags            []string
+	ServiceAccounts []string
+}
+
+func (p *gcpProvider) Fetch() (*inventory, error) {
+	ctx := context.Background()
+	options := []option.ClientOption{}
+	if p.Cfg.Endpoint != "" {
+		options = append(options, option.WithEndpoint(p.Cfg.Endpoint), option.WithoutAuthentication())
+	}
+	service, err := compute.NewService(ctx, options...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to create GCP compute client")
+	}
+
+	inv := &inventory{}
+	for _, project := range p.Cfg.Projects {
+		inv.Projects = append(inv.Projects, projects.Project{ID: project, Name: project, Enabled: true})
+
+		err := service.Networks.List(project).Pages(ctx, func(page *compute.NetworkList) error {
+			for _, network := range page.Items {
+				inv.Networks = append(inv.Networks, networks.Network{ID: network.SelfLink, Name: network.Name, TenantID: project})
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to fetch networks of %s", project)
+		}
+
+		interfaces := []gcpInterface{}
+		err = service.Instances.AggregatedList(project).Pages(ctx, func(page *compute.InstanceAggregatedList) error {
+			for _, scoped := range page.Items {
+				for _, instance := range scoped.Instances {
+					inv.Servers = append(inv.Servers, gcpInstance(project, instance))
+					for _, nic := range instance.NetworkInterfaces {
+						i, fips := gcpNetworkInterface(project, instance, nic)
+						interfaces = append(interfaces, i)
+						inv.FloatingIPs = append(inv.FloatingIPs, fips...)
+					}
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to fetch instances of %s", project)
+		}
+
+		firewalls := []*compute.Firewall{}
+		err = service.Firewalls.List(project).Pages(ctx, func(page *compute.FirewallList) error {
+			for _, firewall := range page.Items {
+				if !firewall.Disabled {
+					firewalls = append(firewalls, firewall)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to fetch firewalls of %s", project)
+		}
+
+		targets := map[string][]string{}
+		all := []firewallRule{}
+		for _, firewall := range firewalls {
+			targets[firewall.Name] = gcpTargets(firewall, interfaces)
+			all = append(all, gcpFirewallRules(firewall, targets[firewall.Name])...)
+		}
+		for _, firewall := range firewalls {
+			sg := gcpSecurityGroup(project, firewall, targets[firewall.Name], all)
+			inv.SecurityGroups = append(inv.SecurityGroups, sg)
+			for i := range interfaces {
+				if contain(targets[firewall.Name], interfaces[i].Port.ID) {
+					interfaces[i].Port.SecurityGroups = append(interfaces[i].Port.SecurityGroups, sg.ID)
+				}
+			}
+		}
+		for _, i := range interfaces {
+			inv.Ports = append(inv.Ports, i.Port)
+		}
+	}
+	return inv, nil
+}
+
+// gcpTargets returns IDs of the ports a firewall rule applies to. A rule without target tags and
+// service accounts applies to every instance in the network.
+func gcpTargets(firewall *compute.Firewall, interfaces []gcpInterface) []string {
+	results := []string{}
+	for _, i := range interfaces {
+		if i.Network != firewall.Network {
+			continue
+		}
+		matched := len(firewall.TargetTags) == 0 && len(firewall.TargetServiceAccounts) == 0
+		for _, tag := range firewall.TargetTags {
+			matched = matched || contain(i.Tags, tag)
+		}
+		for _, account := range firewall.TargetServiceAccounts {
+			matched = matched || contain(i.ServiceAccounts, account)
+		}
+		if matched {
+			results = append(results, i.Port.ID)
+		}
+	}
+	return results
+}
+
+// gcpFirewallRules converts a firewall rule to a rule per allowed or denied protocol.
+func gcpFirewallRules(firewall *compute.Firewall, targets []string) []firewallRule {
+	base := firewallRule{
+		Priority:    int(firewall.Priority),
+		Direction:   strings.ToLower(firewall.Direction),
+		Targets:     targets,
+		Description: firewall.Name,
+	}
+	if base.Direction == "ingress" {
+		base.Remotes = firewall.SourceRanges
+		for _, tag := range firewall.SourceTags {
+			base.RemoteGroups = append(base.RemoteGroups, "tag:"+tag)
+		}
+		for _, account := range firewall.SourceServiceAccounts {
+			base.RemoteGroups = append(base.RemoteGroups, "serviceAccount:"+account)
+		}
+	} else {
+		base.Remotes = firewall.DestinationRanges
+	}
+	// A rule without sources or destinations matches any IPv4 address.
+	if len(base.Remotes) == 0 && len(base.RemoteGroups) == 0 {
+		base.Remotes = []string{"0.0.0.0/0"}
+	}
+
+	results := []firewallRule{}
+	add := func(protocol string, ports []string, deny bool) {
+		rule := base
+		rule.Deny = deny
+		rule.Protocol = firewallProtocol(protocol)
+		if len(ports) > 0 {
+			ranges, err := parsePortRanges(strings.Join(ports, ","))
+			if err != nil {
+				return
+			}
+			rule.Ports = ranges
+		}
+		results = append(results, rule)
+	}
+	for _, allowed := range firewall.Allowed {
+		add(allowed.IPProtocol, allowed.Ports, false)
+	}
+	for _, denied := range firewall.Denied {
+		add(denied.IPProtocol, denied.Ports, true)
+	}
+	return results
+}
+
+func gcpSecurityGroup(project string, firewall *compute.Firewall, targets []string, all []firewallRule) groups.SecGroup {
+	sg := groups.SecGroup{
+		ID:          strconv.FormatUint(firewall.Id, 10),
+		Name:        firewall.Name,
+		Description: firewall.Description,
+		TenantID:    project,
+		ProjectID:   project,
+		Tags: []string{
+			fmt.Sprintf("network=%s", lastPathSegment(firewall.Network)),
+			fmt.Sprintf("priority=%d", firewall.Priority),
+		},
+	}
+	for _, tag := range firewall.TargetTags {
+		sg.Tags = append(sg.Tags, fmt.Sprintf("target_tag=%s", tag))
+	}
+	for _, account := range firewall.TargetServiceAccounts {
+		sg.Tags = append(sg.Tags, fmt.Sprintf("target_service_account=%s", account))
+	}
+	if created, err := time.Parse(time.RFC3339, firewall.CreationTimestamp); err == nil {
+		sg.CreatedAt = created
+		sg.UpdatedAt = created
+	}
+	for _, rule := range gcpFirewallRules(firewall, targets) {
+		sg.Rules = append(sg.Rules, rule.allowedRules(all)...)
+	}
+	for i := range sg.Rules {
+		sg.Rules[i].ID = fmt.Sprintf("%s-%d", sg.ID, i)
+		sg.Rules[i].SecGroupID = sg.ID
+		sg.Rules[i].TenantID = project
+		sg.Rules[i].ProjectID = project
+	}
+	return sg
+}
+
+// gcpNetworkInterface converts a network interface to a port and its external IPs to floating IPs.
+func gcpNetworkInterface(project string, instance *compute.Instance, nic *compute.NetworkInterface) (gcpInterface, []floatingips.FloatingIP) {
+	portSecurityEnabled := true
+	i := gcpInterface{
+		Port: neutronPort{
+			Port: ports.Port{
+				ID:             fmt.Sprintf("%d/%s", instance.Id, nic.Name),
+				NetworkID:      nic.Network,
+				Name:           fmt.Sprintf("%s/%s", instance.Name, nic.Name),
+				AdminStateUp:   true,
+				Status:         instance.Status,
+				FixedIPs:       []ports.IP{{SubnetID: nic.Subnetwork, IPAddress: nic.NetworkIP}},
+				TenantID:       project,
+				ProjectID:      project,
+				DeviceOwner:    "compute",
+				DeviceID:       strconv.FormatUint(instance.Id, 10),
+				SecurityGroups: []string{},
+			},
+			PortSecurityEnabled: &portSecurityEnabled,
+		},
+		Network: nic.Network,
+	}
+	if nic.Ipv6Address != "" {
+		i.Port.FixedIPs = append(i.Port.FixedIPs, ports.IP{SubnetID: nic.Subnetwork, IPAddress: nic.Ipv6Address})
+	}
+	if instance.Tags != nil {
+		i.Tags = instance.Tags.Items
+	}
+	for _, account := range instance.ServiceAccounts {
+		i.ServiceAccounts = append(i.ServiceAccounts, account.Email)
+	}
+
+	fips := []floatingips.FloatingIP{}
+	for _, config := range nic.AccessConfigs {
+		if config.NatIP == "" {
+			continue
+		}
+		fips = append(fips, floatingips.FloatingIP{
+			ID:         config.NatIP,
+			FloatingIP: config.NatIP,
+			FixedIP:    nic.NetworkIP,
+			PortID:     i.Port.ID,
+			TenantID:   project,
+			ProjectID:  project,
+			Status:     "ACTIVE",
+		})
+	}
+	return i, fips
+}
+
+// gcpInstance converts an instance to a server whose metadata are the labels of the instance.
+func gcpInstance(project string, instance *compute.Instance) servers.Server {
+	server := servers.Server{
+		ID:       strconv.FormatUint(instance.Id, 10),
+		TenantID: project,
+		Name:     instance.Name,
+		Status:   instance.Status,
+		Metadata: map[string]string{},
+	}
+	for k, v := range instance.Labels {
+		server.Metadata[k] = v
+	}
+	if created, err := time.Parse(time.RFC3339, instance.CreationTimestamp); err == nil {
+		server.Created = created
+	}
+	return server
+}
+
+func lastPathSegment(s string) string {
+	return s[strings.LastIndex(s, "/")+1:]
+}
@@ -1,7 +1,13 @@
 module github.com/takaishi/sg_inspector
 
 require (
+	github.com/Azure/azure-sdk-for-go v48.2.0+incompatible
+	github.com/Azure/go-autorest/autorest v0.11.12
+	github.com/Azure/go-autorest/autorest/azure/auth v0.5.3
+	github.com/Azure/go-autorest/autorest/to v0.4.1
+	github.com/Azure/go-autorest/autorest/validation v0.3.1 // indirect
 	github.com/BurntSushi/toml v0.3.1
+	github.com/aws/aws-sdk-go v1.35.20
 	github.com/go-playground/validator/v10 v10.2.0
 	github.com/go-redis/redis/v8 v8.0.0-beta.5
 	github.com/gophercloud/gophercloud v0.7.0
@@ -12,6 +18,7 @@ require (
 	github.com/sirupsen/logrus v1.4.2
 	github.com/slack-go/slack v0.6.5
 	github.com/urfave/cli v1.20.0
+	google.golang.org/api v0.35.0
 	gopkg.in/yaml.v2 v2.2.8 // indirect
 )
 
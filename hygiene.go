@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/security/groups"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// RunHygiene posts a digest of unused, stale and duplicate security groups and of allow rules
+// that no longer have any effect.
+func (checker *OpenStackSecurityGroupChecker) RunHygiene() error {
+	checker.mu.Lock()
+	defer checker.mu.Unlock()
+
+	logrus.Info("Start to find unused, stale and duplicate security groups.")
+
+	inv, err := checker.fetchInventory()
+	if err != nil {
+		return err
+	}
+
+	sections := []Section{}
+	if items := checker.unusedGroups(inv); len(items) > 0 {
+		sections = append(sections, hygieneSection("Unused security groups", items))
+	}
+	if checker.Cfg.Hygiene.StaleDays > 0 {
+		if items := checker.staleGroups(inv, time.Now()); len(items) > 0 {
+			sections = append(sections, hygieneSection(fmt.Sprintf("Security groups unchanged for %d days", checker.Cfg.Hygiene.StaleDays), items))
+		}
+	}
+	if items := checker.duplicateGroups(inv); len(items) > 0 {
+		sections = append(sections, hygieneSection("Security groups with identical rules", items))
+	}
+	sections = append(sections, allowlistSections(checker.allowlistHygiene(inv))...)
+
+	if len(sections) == 0 {
+		logrus.Info("No unused, stale or duplicate security group and no stale allow rule is found.")
+		return nil
+	}
+	logrus.Info("Unused, stale or duplicate security group or stale allow rule is found.")
+	if checker.Cfg.DryRun {
+		return nil
+	}
+	notification := Notification{Prefix: checker.Cfg.Hygiene.PrefixMessage, Suffix: checker.Cfg.Hygiene.SuffixMessage, Sections: sections}
+	if err := checker.notify(notification); err != nil {
+		return errors.Wrapf(err, "Failed to post hygiene report")
+	}
+	return nil
+}
+
+func hygieneSection(title string, items []string) Section {
+	return Section{Title: fmt.Sprintf("%s (%d)", title, len(items)), Items: items}
+}
+
+func (checker *OpenStackSecurityGroupChecker) describeGroup(sg groups.SecGroup) string {
+	projectName, err := getProjectNameFromID(sg.TenantID, checker.Projects)
+	if err != nil {
+		projectName = sg.TenantID
+	}
+	return fmt.Sprintf("%s / %s (%s)", projectName, sg.Name, sg.ID)
+}
+
+// unusedGroups returns groups that are not attached to any port.
+// "default" groups are excluded since every project has one.
+func (checker *OpenStackSecurityGroupChecker) unusedGroups(inv *inventory) []string {
+	items := []string{}
+	for _, sg := range inv.SecurityGroups {
+		if sg.Name == "default" || len(memberPortIDs(sg, inv.Ports)) > 0 {
+			continue
+		}
+		items = append(items, checker.describeGroup(sg))
+	}
+	return items
+}
+
+// staleGroups returns groups that have not been updated for stale_days.
+func (checker *OpenStackSecurityGroupChecker) staleGroups(inv *inventory, now time.Time) []string {
+	threshold := now.AddDate(0, 0, -checker.Cfg.Hygiene.StaleDays)
+	items := []string{}
+	for _, sg := range inv.SecurityGroups {
+		updated := sg.UpdatedAt
+		if updated.IsZero() {
+			updated = sg.CreatedAt
+		}
+		if updated.IsZero() || updated.After(threshold) {
+			continue
+		}
+		items = append(items, fmt.Sprintf("%s, updated: %s", checker.describeGroup(sg), updated.Local().Format("2006-01-02")))
+	}
+	return items
+}
+
+// ruleSetSignature returns a string that is equal for groups with identical rules.
+// References to the group itself are normalized so that copies in other projects match.
+func ruleSetSignature(sg groups.SecGroup) string {
+	items := []string{}
+	for _, rule := range sg.Rules {
+		remoteGroup := rule.RemoteGroupID
+		if remoteGroup == sg.ID {
+			remoteGroup = "self"
+		}
+		items = append(items, fmt.Sprintf("%s|%s|%s|%s|%s|%s", rule.Direction, rule.EtherType, normalizeProtocol(rule.Protocol), formatPortRange(rule), rule.RemoteIPPrefix, remoteGroup))
+	}
+	sort.Strings(items)
+	return strings.Join(items, "\n")
+}
+
+// duplicateGroups returns sets of groups that have identical rules.
+func (checker *OpenStackSecurityGroupChecker) duplicateGroups(inv *inventory) []string {
+	signatures := []string{}
+	duplicates := map[string][]groups.SecGroup{}
+	for _, sg := range inv.SecurityGroups {
+		if sg.Name == "default" || len(sg.Rules) == 0 {
+			continue
+		}
+		signature := ruleSetSignature(sg)
+		if _, ok := duplicates[signature]; !ok {
+			signatures = append(signatures, signature)
+		}
+		duplicates[signature] = append(duplicates[signature], sg)
+	}
+
+	items := []string{}
+	for _, signature := range signatures {
+		sgs := duplicates[signature]
+		if len(sgs) < 2 {
+			continue
+		}
+		names := []string{}
+		for _, sg := range sgs {
+			names = append(names, checker.describeGroup(sg))
+		}
+		items = append(items, strings.Join(names, ", "))
+	}
+	return items
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/servers"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/layer3/floatingips"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/security/groups"
+	"github.com/gophercloud/gophercloud/pagination"
+)
+
+// instanceInfo describes a Nova server attached to a port, used to enrich findings.
+type instanceInfo struct {
+	ID        string            `json:"id"`
+	Name      string            `json:"name"`
+	Status    string            `json:"status"`
+	Metadata  map[string]string `json:"metadata"`
+	PublicIPs []string          `json:"public_ips"`
+}
+
+func (i instanceInfo) String() string {
+	items := []string{fmt.Sprintf("%s (%s)", i.Name, i.Status)}
+	keys := []string{}
+	for k := range i.Metadata {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		items = append(items, fmt.Sprintf("%s=%s", k, i.Metadata[k]))
+	}
+	items = append(items, i.PublicIPs...)
+	return strings.Join(items, " ")
+}
+
+func (p *openStackProvider) fetchServers(client *gophercloud.ProviderClient, eo gophercloud.EndpointOpts) (results []servers.Server, err error) {
+	computeClient, err := openstack.NewComputeV2(client, eo)
+	if err != nil {
+		return
+	}
+
+	err = servers.List(computeClient, servers.ListOpts{AllTenants: true}).EachPage(func(page pagination.Page) (bool, error) {
+		extracted, err := servers.ExtractServers(page)
+		if err != nil {
+			return false, err
+		}
+		for _, server := range extracted {
+			results = append(results, server)
+		}
+		return true, nil
+	})
+	return
+}
+
+// instanceOf returns the server attached to the port, if any.
+func (checker *OpenStackSecurityGroupChecker) instanceOf(port neutronPort, fips []floatingips.FloatingIP) (instanceInfo, bool, error) {
+	server, ok := checker.servers[port.DeviceID]
+	if !ok {
+		return instanceInfo{}, false, nil
+	}
+	addresses, err := checker.publicAddresses(port, fips)
+	if err != nil {
+		return instanceInfo{}, false, err
+	}
+	info := instanceInfo{
+		ID:        server.ID,
+		Name:      server.Name,
+		Status:    server.Status,
+		Metadata:  map[string]string{},
+		PublicIPs: []string{},
+	}
+	for _, key := range checker.Cfg.MetadataKeys {
+		if v, ok := server.Metadata[key]; ok {
+			info.Metadata[key] = v
+		}
+	}
+	for _, address := range addresses {
+		info.PublicIPs = append(info.PublicIPs, address.Address)
+	}
+	return info, true, nil
+}
+
+// instancesOf returns the servers whose ports the security group is attached to.
+func (checker *OpenStackSecurityGroupChecker) instancesOf(sg groups.SecGroup, ports []neutronPort, fips []floatingips.FloatingIP) ([]instanceInfo, error) {
+	instances := []instanceInfo{}
+	for _, port := range ports {
+		if !contain(port.SecurityGroups, sg.ID) {
+			continue
+		}
+		info, ok, err := checker.instanceOf(port, fips)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			instances = append(instances, info)
+		}
+	}
+	return instances, nil
+}
+
+func formatInstances(instances []instanceInfo) string {
+	lines := []string{}
+	for _, i := range instances {
+		lines = append(lines, i.String())
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"math/big"
+	"net"
+)
+
+// defaultPrivateRanges are always internal, private_ranges are added to them.
+// RFC1918, RFC6598 (CGNAT) and IPv6 unique local addresses.
+var defaultPrivateRanges = []string{
+	"10.0.0.0/8",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"100.64.0.0/10",
+	"fc00::/7",
+}
+
+// reservedRanges are never reachable from the internet regardless of the configuration:
+// loopback, link-local unicast and link-local multicast.
+var reservedRanges = []string{
+	"127.0.0.0/8",
+	"169.254.0.0/16",
+	"224.0.0.0/24",
+	"::1/128",
+	"fe80::/10",
+	"ff02::/16",
+}
+
+// publicUniverseCIDRs are the address spaces routable on the internet.
+// For IPv6 only global unicast (2000::/3) is considered.
+var publicUniverseCIDRs = []string{
+	"0.0.0.0/0",
+	"2000::/3",
+}
+
+// isWorldPrefix returns true if prefix matches any IPv4 or IPv6 address (e.g. 0.0.0.0/0, ::/0).
+func isWorldPrefix(prefix string) bool {
+	_, ipnet, err := net.ParseCIDR(prefix)
+	if err != nil {
+		return false
+	}
+	ones, _ := ipnet.Mask.Size()
+	return ones == 0
+}
+
+func parseCIDRs(cidrs []string) ([]*net.IPNet, error) {
+	blocks := []*net.IPNet{}
+	for _, cidr := range cidrs {
+		_, block, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, block)
+	}
+	return blocks, nil
+}
+
+// isPrivateIP returns true if the address is not reachable from the internet, i.e. it is in a
+// private or reserved range, or outside the public address space.
+func isPrivateIP(ip net.IP, privateRanges []string) (bool, error) {
+	if ip == nil {
+		return false, nil
+	}
+	private, err := parseCIDRs(append(append([]string{}, privateRanges...), reservedRanges...))
+	if err != nil {
+		return true, err
+	}
+	for _, block := range private {
+		if block.Contains(ip) {
+			return true, nil
+		}
+	}
+
+	universe, err := parseCIDRs(publicUniverseCIDRs)
+	if err != nil {
+		return true, err
+	}
+	for _, block := range universe {
+		if block.Contains(ip) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// prefixSize returns the number of addresses in the prefix.
+func prefixSize(prefix *net.IPNet) *big.Int {
+	ones, bits := prefix.Mask.Size()
+	return new(big.Int).Lsh(big.NewInt(1), uint(bits-ones))
+}
+
+// intersectionSize returns the number of addresses shared by two prefixes.
+// Two CIDR prefixes either don't overlap or one contains the other.
+func intersectionSize(a, b *net.IPNet) *big.Int {
+	aOnes, aBits := a.Mask.Size()
+	bOnes, bBits := b.Mask.Size()
+	if aBits != bBits {
+		return big.NewInt(0)
+	}
+	if aOnes >= bOnes && b.Contains(a.IP) {
+		return prefixSize(a)
+	}
+	if bOnes >= aOnes && a.Contains(b.IP) {
+		return prefixSize(b)
+	}
+	return big.NewInt(0)
+}
+
+// disjointPrefixes drops prefixes that are contained in another prefix of the list,
+// so that the sizes of the remaining prefixes can be summed up.
+func disjointPrefixes(prefixes []*net.IPNet) []*net.IPNet {
+	result := []*net.IPNet{}
+	for i, p := range prefixes {
+		contained := false
+		for j, q := range prefixes {
+			if i == j {
+				continue
+			}
+			pOnes, _ := p.Mask.Size()
+			qOnes, _ := q.Mask.Size()
+			if intersectionSize(p, q).Sign() > 0 && (qOnes < pOnes || (qOnes == pOnes && j < i)) {
+				contained = true
+				break
+			}
+		}
+		if !contained {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// publicAddressCount returns how many public addresses are included in the prefix.
+func publicAddressCount(prefix *net.IPNet, privateRanges []string) (*big.Int, error) {
+	universe, err := parseCIDRs(publicUniverseCIDRs)
+	if err != nil {
+		return nil, err
+	}
+	private, err := parseCIDRs(append(append([]string{}, privateRanges...), reservedRanges...))
+	if err != nil {
+		return nil, err
+	}
+	private = disjointPrefixes(private)
+
+	count := big.NewInt(0)
+	for _, u := range universe {
+		count.Add(count, intersectionSize(prefix, u))
+	}
+	for _, p := range private {
+		for _, u := range universe {
+			if intersectionSize(p, u).Sign() > 0 {
+				count.Sub(count, intersectionSize(prefix, p))
+				break
+			}
+		}
+	}
+	return count, nil
+}
+
+// exceedsPublicPrefixSize returns true if the prefix exposes more public addresses than a prefix of
+// length maxPrefixSize. maxPrefixSize <= 0 disables the check.
+func exceedsPublicPrefixSize(prefix *net.IPNet, maxPrefixSize int, privateRanges []string) (bool, *big.Int, error) {
+	count, err := publicAddressCount(prefix, privateRanges)
+	if err != nil {
+		return false, nil, err
+	}
+	_, bits := prefix.Mask.Size()
+	if maxPrefixSize <= 0 || maxPrefixSize > bits {
+		return false, count, nil
+	}
+	limit := new(big.Int).Lsh(big.NewInt(1), uint(bits-maxPrefixSize))
+	return count.Cmp(limit) > 0, count, nil
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"math/big"
+	"net"
+	"testing"
+)
+
+func TestIsPrivateIP(t *testing.T) {
+	tests := []struct {
+		ip            string
+		privateRanges []string
+		want          bool
+	}{
+		{"10.1.2.3", defaultPrivateRanges, true},
+		{"172.31.255.255", defaultPrivateRanges, true},
+		{"172.32.0.1", defaultPrivateRanges, false},
+		{"192.168.0.1", defaultPrivateRanges, true},
+		{"100.64.0.1", defaultPrivateRanges, true},
+		{"100.128.0.1", defaultPrivateRanges, false},
+		{"127.0.0.1", defaultPrivateRanges, true},
+		{"169.254.169.254", defaultPrivateRanges, true},
+		{"224.0.0.251", defaultPrivateRanges, true},
+		{"8.8.8.8", defaultPrivateRanges, false},
+		{"203.0.113.10", defaultPrivateRanges, false},
+		{"203.0.113.10", append([]string{"203.0.113.0/24"}, defaultPrivateRanges...), true},
+		{"10.1.2.3", []string{"203.0.113.0/24"}, false},
+		{"::1", defaultPrivateRanges, true},
+		{"fe80::1", defaultPrivateRanges, true},
+		{"fd00::1", defaultPrivateRanges, true},
+		{"ff02::1", defaultPrivateRanges, true},
+		{"2001:db8::1", defaultPrivateRanges, false},
+		{"3fff::1", defaultPrivateRanges, false},
+		{"4000::1", defaultPrivateRanges, true},
+	}
+	for _, tt := range tests {
+		got, err := isPrivateIP(net.ParseIP(tt.ip), tt.privateRanges)
+		if err != nil {
+			t.Fatalf("isPrivateIP(%s): %s", tt.ip, err)
+		}
+		if got != tt.want {
+			t.Errorf("isPrivateIP(%s, %v) = %v, want %v", tt.ip, tt.privateRanges, got, tt.want)
+		}
+	}
+}
+
+func TestPublicAddressCount(t *testing.T) {
+	pow2 := func(n uint) *big.Int { return new(big.Int).Lsh(big.NewInt(1), n) }
+	sub := func(a *big.Int, bs ...*big.Int) *big.Int {
+		result := new(big.Int).Set(a)
+		for _, b := range bs {
+			result.Sub(result, b)
+		}
+		return result
+	}
+	tests := []struct {
+		prefix        string
+		privateRanges []string
+		want          *big.Int
+	}{
+		// 10/8, 172.16/12, 192.168/16, 100.64/10, 127/8, 169.254/16 and 224.0.0/24 are excluded.
+		{"0.0.0.0/0", defaultPrivateRanges, sub(pow2(32), pow2(24), pow2(20), pow2(16), pow2(22), pow2(24), pow2(16), pow2(8))},
+		// 0.0.0.0/1 straddles 10/8, 100.64/10 and 127/8 only.
+		{"0.0.0.0/1", defaultPrivateRanges, sub(pow2(31), pow2(24), pow2(22), pow2(24))},
+		{"10.0.0.0/7", defaultPrivateRanges, pow2(24)},
+		{"172.0.0.0/8", defaultPrivateRanges, sub(pow2(24), pow2(20))},
+		{"100.0.0.0/8", defaultPrivateRanges, sub(pow2(24), pow2(22))},
+		{"192.168.1.0/24", defaultPrivateRanges, big.NewInt(0)},
+		{"8.8.8.0/24", defaultPrivateRanges, big.NewInt(256)},
+		{"203.0.112.0/23", []string{"203.0.113.0/24"}, big.NewInt(256)},
+		// Overlapping private ranges are subtracted once.
+		{"10.0.0.0/7", []string{"10.0.0.0/8", "10.1.0.0/16"}, pow2(24)},
+		// Only global unicast (2000::/3) is public.
+		{"::/0", defaultPrivateRanges, pow2(125)},
+		{"::/2", defaultPrivateRanges, pow2(125)},
+		{"fc00::/6", defaultPrivateRanges, big.NewInt(0)},
+		{"fd00::/8", defaultPrivateRanges, big.NewInt(0)},
+		{"2001:db8::/32", defaultPrivateRanges, pow2(96)},
+		{"2001:db8::/32", []string{"2001:db8:1::/48"}, sub(pow2(96), pow2(80))},
+	}
+	for _, tt := range tests {
+		_, prefix, err := net.ParseCIDR(tt.prefix)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := publicAddressCount(prefix, tt.privateRanges)
+		if err != nil {
+			t.Fatalf("publicAddressCount(%s): %s", tt.prefix, err)
+		}
+		if got.Cmp(tt.want) != 0 {
+			t.Errorf("publicAddressCount(%s, %v) = %s, want %s", tt.prefix, tt.privateRanges, got, tt.want)
+		}
+	}
+}
+
+func TestExceedsPublicPrefixSize(t *testing.T) {
+	tests := []struct {
+		prefix        string
+		maxPrefixSize int
+		want          bool
+	}{
+		{"0.0.0.0/1", 16, true},
+		{"1.0.0.0/8", 16, true},
+		{"1.0.0.0/16", 16, false},
+		{"10.0.0.0/7", 8, false},
+		{"10.0.0.0/7", 9, true},
+		{"0.0.0.0/0", 0, false},
+		{"2001:db8::/32", 48, true},
+		{"fd00::/8", 48, false},
+	}
+	for _, tt := range tests {
+		_, prefix, err := net.ParseCIDR(tt.prefix)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, _, err := exceedsPublicPrefixSize(prefix, tt.maxPrefixSize, defaultPrivateRanges)
+		if err != nil {
+			t.Fatalf("exceedsPublicPrefixSize(%s): %s", tt.prefix, err)
+		}
+		if got != tt.want {
+			t.Errorf("exceedsPublicPrefixSize(%s, %d) = %v, want %v", tt.prefix, tt.maxPrefixSize, got, tt.want)
+		}
+	}
+}
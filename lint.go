@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/security/groups"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/security/rules"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli"
+)
+
+const (
+	lintDuplicate = "duplicate"
+	lintShadowed  = "shadowed"
+	lintMergeable = "mergeable"
+)
+
+// lintIssue is a redundant or mergeable rule found in a security group.
+type lintIssue struct {
+	Kind      string   `json:"kind"`
+	Tenant    string   `json:"tenant"`
+	GroupID   string   `json:"group_id"`
+	GroupName string   `json:"group_name"`
+	RuleIDs   []string `json:"rule_ids"`
+	Message   string   `json:"message"`
+}
+
+func StartLint(c *cli.Context) error {
+	cfg, err := ReadConfig(c.String("config"), true)
+	if err != nil {
+		return err
+	}
+
+	checker := NewOpenStackChecker(cfg, nil)
+	inv, err := checker.fetchInventory()
+	if err != nil {
+		return errors.Wrap(err, "Failed to fetch resources")
+	}
+
+	issues := []lintIssue{}
+	for _, sg := range inv.SecurityGroups {
+		projectName, err := getProjectNameFromID(sg.TenantID, checker.Projects)
+		if err != nil {
+			projectName = sg.TenantID
+		}
+		for _, issue := range lintGroup(sg) {
+			issue.Tenant = projectName
+			issues = append(issues, issue)
+		}
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(issues)
+}
+
+// lintGroup detects duplicate rules, rules covered by a broader rule and rules whose port ranges
+// can be merged.
+func lintGroup(sg groups.SecGroup) []lintIssue {
+	issues := []lintIssue{}
+	redundant := map[string]bool{}
+	for i, a := range sg.Rules {
+		for j, b := range sg.Rules {
+			if i == j || redundant[b.ID] {
+				continue
+			}
+			if i > j && sameRule(a, b) {
+				redundant[a.ID] = true
+				issues = append(issues, newLintIssue(lintDuplicate, sg, a, b, fmt.Sprintf("%s is identical to rule %s", describeRule(a), b.ID)))
+				break
+			}
+			if !sameRule(a, b) && coversRule(b, a) {
+				redundant[a.ID] = true
+				issues = append(issues, newLintIssue(lintShadowed, sg, a, b, fmt.Sprintf("%s is covered by %s", describeRule(a), describeRule(b))))
+				break
+			}
+		}
+	}
+
+	for i, a := range sg.Rules {
+		for _, b := range sg.Rules[i+1:] {
+			if redundant[a.ID] || redundant[b.ID] {
+				continue
+			}
+			if merged, ok := mergeRules(a, b); ok {
+				issues = append(issues, newLintIssue(lintMergeable, sg, a, b, fmt.Sprintf("%s and %s can be merged into %s", describeRule(a), describeRule(b), merged)))
+			}
+		}
+	}
+	return issues
+}
+
+func newLintIssue(kind string, sg groups.SecGroup, a, b rules.SecGroupRule, message string) lintIssue {
+	return lintIssue{
+		Kind:      kind,
+		GroupID:   sg.ID,
+		GroupName: sg.Name,
+		RuleIDs:   []string{a.ID, b.ID},
+		Message:   message,
+	}
+}
+
+func describeRule(rule rules.SecGroupRule) string {
+	return fmt.Sprintf("%s %s %s/%s from %s", rule.Direction, rule.EtherType, normalizeProtocol(rule.Protocol), formatPortRange(rule), effectiveSource(rule))
+}
+
+func sameTraffic(a, b rules.SecGroupRule) bool {
+	return a.Direction == b.Direction && a.EtherType == b.EtherType
+}
+
+// sameRule returns true if both rules match exactly the same traffic.
+func sameRule(a, b rules.SecGroupRule) bool {
+	if !sameTraffic(a, b) || normalizeProtocol(a.Protocol) != normalizeProtocol(b.Protocol) {
+		return false
+	}
+	if rulePortRange(a) != rulePortRange(b) || formatPortRange(a) != formatPortRange(b) {
+		return false
+	}
+	return sameSource(effectiveSource(a), effectiveSource(b))
+}
+
+func sameSource(a, b ruleSource) bool {
+	if a.RemoteGroupID != "" || b.RemoteGroupID != "" {
+		return a.RemoteGroupID == b.RemoteGroupID
+	}
+	_, pa, errA := net.ParseCIDR(a.Prefix)
+	_, pb, errB := net.ParseCIDR(b.Prefix)
+	if errA != nil || errB != nil {
+		return a.Prefix == b.Prefix
+	}
+	return pa.String() == pb.String()
+}
+
+// coversSource returns true if every address matched by inner is matched by outer.
+func coversSource(outer, inner ruleSource) bool {
+	if outer.RemoteGroupID != "" {
+		return outer.RemoteGroupID == inner.RemoteGroupID
+	}
+	if inner.RemoteGroupID != "" {
+		return outer.IsWorld()
+	}
+	_, po, errO := net.ParseCIDR(outer.Prefix)
+	_, pi, errI := net.ParseCIDR(inner.Prefix)
+	if errO != nil || errI != nil {
+		return false
+	}
+	return prefixContains(po, pi)
+}
+
+// coversRule returns true if outer matches all the traffic matched by inner.
+func coversRule(outer, inner rules.SecGroupRule) bool {
+	if !sameTraffic(outer, inner) || !coversSource(effectiveSource(outer), effectiveSource(inner)) {
+		return false
+	}
+	op, ip := normalizeProtocol(outer.Protocol), normalizeProtocol(inner.Protocol)
+	if op == "any" {
+		return true
+	}
+	if op != ip {
+		return false
+	}
+	if protocolHasPorts(op) {
+		or, ir := rulePortRange(outer), rulePortRange(inner)
+		return or.Min <= ir.Min && ir.Max <= or.Max
+	}
+	return formatPortRange(outer) == "any" || formatPortRange(outer) == formatPortRange(inner)
+}
+
+// mergeRules returns the merged port range if the rules differ only in overlapping or adjacent
+// port ranges.
+func mergeRules(a, b rules.SecGroupRule) (string, bool) {
+	protocol := normalizeProtocol(a.Protocol)
+	if !sameTraffic(a, b) || protocol != normalizeProtocol(b.Protocol) || !protocolHasPorts(protocol) {
+		return "", false
+	}
+	if !sameSource(effectiveSource(a), effectiveSource(b)) {
+		return "", false
+	}
+	merged := portRanges{rulePortRange(a), rulePortRange(b)}.normalize()
+	if len(merged) != 1 {
+		return "", false
+	}
+	return fmt.Sprintf("%s/%s", protocol, merged[0]), true
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/security/groups"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/security/rules"
+)
+
+func ingress(id, etherType, protocol string, min, max int, prefix string) rules.SecGroupRule {
+	return rules.SecGroupRule{ID: id, Direction: "ingress", EtherType: etherType, Protocol: protocol, PortRangeMin: min, PortRangeMax: max, RemoteIPPrefix: prefix}
+}
+
+func TestCoversRule(t *testing.T) {
+	tests := []struct {
+		name         string
+		outer, inner rules.SecGroupRule
+		want         bool
+	}{
+		{"wider port range", ingress("a", "IPv4", "tcp", 1, 1024, "0.0.0.0/0"), ingress("b", "IPv4", "tcp", 22, 22, "0.0.0.0/0"), true},
+		{"all ports", ingress("a", "IPv4", "tcp", 0, 0, "10.0.0.0/8"), ingress("b", "IPv4", "tcp", 80, 443, "10.0.0.0/8"), true},
+		{"partial port overlap", ingress("a", "IPv4", "tcp", 80, 443, "0.0.0.0/0"), ingress("b", "IPv4", "tcp", 443, 8443, "0.0.0.0/0"), false},
+		{"wider prefix", ingress("a", "IPv4", "tcp", 22, 22, "10.0.0.0/8"), ingress("b", "IPv4", "tcp", 22, 22, "10.1.0.0/16"), true},
+		{"narrower prefix", ingress("a", "IPv4", "tcp", 22, 22, "10.1.0.0/16"), ingress("b", "IPv4", "tcp", 22, 22, "10.0.0.0/8"), false},
+		{"implicit source", ingress("a", "IPv4", "tcp", 22, 22, ""), ingress("b", "IPv4", "tcp", 22, 22, "198.51.100.0/24"), true},
+		{"any protocol", ingress("a", "IPv4", "", 0, 0, "0.0.0.0/0"), ingress("b", "IPv4", "udp", 53, 53, "0.0.0.0/0"), true},
+		{"protocol number", ingress("a", "IPv4", "6", 0, 0, "0.0.0.0/0"), ingress("b", "IPv4", "tcp", 22, 22, "0.0.0.0/0"), true},
+		{"other protocol", ingress("a", "IPv4", "udp", 0, 0, "0.0.0.0/0"), ingress("b", "IPv4", "tcp", 22, 22, "0.0.0.0/0"), false},
+		{"icmp any type", ingress("a", "IPv4", "icmp", 0, 0, "0.0.0.0/0"), ingress("b", "IPv4", "icmp", 8, 0, "0.0.0.0/0"), true},
+		{"icmp other type", ingress("a", "IPv4", "icmp", 0, 3, "0.0.0.0/0"), ingress("b", "IPv4", "icmp", 8, 0, "0.0.0.0/0"), false},
+		{"IPv4 world and IPv6 rule", ingress("a", "IPv4", "tcp", 22, 22, "0.0.0.0/0"), ingress("b", "IPv6", "tcp", 22, 22, "2001:db8::/32"), false},
+		{"IPv6 world and IPv6 rule", ingress("a", "IPv6", "tcp", 22, 22, "::/0"), ingress("b", "IPv6", "tcp", 22, 22, "2001:db8::/32"), true},
+		{"IPv6 implicit and IPv4 rule", ingress("a", "IPv6", "tcp", 22, 22, ""), ingress("b", "IPv4", "tcp", 22, 22, "10.0.0.0/8"), false},
+	}
+	for _, tt := range tests {
+		if got := coversRule(tt.outer, tt.inner); got != tt.want {
+			t.Errorf("%s: coversRule(%s, %s) = %v, want %v", tt.name, describeRule(tt.outer), describeRule(tt.inner), got, tt.want)
+		}
+	}
+
+	group := rules.SecGroupRule{ID: "g", Direction: "ingress", EtherType: "IPv4", Protocol: "tcp", PortRangeMin: 22, PortRangeMax: 22, RemoteGroupID: "admin"}
+	if !coversRule(ingress("a", "IPv4", "tcp", 22, 22, "0.0.0.0/0"), group) {
+		t.Error("world doesn't cover a remote group rule")
+	}
+	if coversRule(group, ingress("a", "IPv4", "tcp", 22, 22, "10.0.0.0/8")) {
+		t.Error("remote group rule covers a prefix rule")
+	}
+}
+
+func TestMergeRules(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b rules.SecGroupRule
+		want string
+		ok   bool
+	}{
+		{"adjacent", ingress("a", "IPv4", "tcp", 80, 89, "0.0.0.0/0"), ingress("b", "IPv4", "tcp", 90, 99, "0.0.0.0/0"), "tcp/80-99", true},
+		{"overlapping", ingress("a", "IPv4", "udp", 1000, 2000, "10.0.0.0/8"), ingress("b", "IPv4", "udp", 1500, 3000, "10.0.0.0/8"), "udp/1000-3000", true},
+		{"gap", ingress("a", "IPv4", "tcp", 80, 80, "0.0.0.0/0"), ingress("b", "IPv4", "tcp", 443, 443, "0.0.0.0/0"), "", false},
+		{"other source", ingress("a", "IPv4", "tcp", 80, 89, "10.0.0.0/8"), ingress("b", "IPv4", "tcp", 90, 99, "0.0.0.0/0"), "", false},
+		{"other protocol", ingress("a", "IPv4", "tcp", 80, 89, "0.0.0.0/0"), ingress("b", "IPv4", "udp", 90, 99, "0.0.0.0/0"), "", false},
+		{"IPv4 and IPv6", ingress("a", "IPv4", "tcp", 80, 89, ""), ingress("b", "IPv6", "tcp", 90, 99, ""), "", false},
+		{"IPv6", ingress("a", "IPv6", "tcp", 80, 89, "2001:db8::/32"), ingress("b", "IPv6", "tcp", 90, 99, "2001:db8::/32"), "tcp/80-99", true},
+		{"icmp", ingress("a", "IPv4", "icmp", 8, 0, "0.0.0.0/0"), ingress("b", "IPv4", "icmp", 0, 0, "0.0.0.0/0"), "", false},
+	}
+	for _, tt := range tests {
+		got, ok := mergeRules(tt.a, tt.b)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("%s: mergeRules = %q, %v, want %q, %v", tt.name, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestLintGroup(t *testing.T) {
+	sg := groups.SecGroup{ID: "sg", Name: "web", Rules: []rules.SecGroupRule{
+		ingress("ssh", "IPv4", "tcp", 22, 22, "10.0.0.0/8"),
+		ingress("ssh-dup", "IPv4", "tcp", 22, 22, "10.0.0.0/8"),
+		ingress("ssh-office", "IPv4", "tcp", 22, 22, "10.1.0.0/16"),
+		ingress("http", "IPv4", "tcp", 80, 80, "0.0.0.0/0"),
+		ingress("http-alt", "IPv4", "tcp", 81, 90, "0.0.0.0/0"),
+		ingress("http-v6", "IPv6", "tcp", 80, 80, "::/0"),
+		ingress("dns", "IPv6", "udp", 53, 53, "2001:db8::/32"),
+		ingress("any-v6", "IPv6", "", 0, 0, "::/0"),
+	}}
+
+	got := []string{}
+	for _, issue := range lintGroup(sg) {
+		got = append(got, issue.Kind+":"+issue.RuleIDs[0]+","+issue.RuleIDs[1])
+	}
+	want := []string{
+		"duplicate:ssh-dup,ssh",
+		"shadowed:ssh-office,ssh",
+		"shadowed:http-v6,any-v6",
+		"shadowed:dns,any-v6",
+		"mergeable:http,http-alt",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("lintGroup = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack"
+	"github.com/gophercloud/gophercloud/openstack/loadbalancer/v2/listeners"
+	"github.com/gophercloud/gophercloud/openstack/loadbalancer/v2/loadbalancers"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/security/rules"
+	"github.com/gophercloud/gophercloud/pagination"
+)
+
+func (p *openStackProvider) fetchLoadBalancers(client *gophercloud.ProviderClient, eo gophercloud.EndpointOpts) (results []loadbalancers.LoadBalancer, err error) {
+	lbClient, err := openstack.NewLoadBalancerV2(client, eo)
+	if err != nil {
+		return
+	}
+
+	err = loadbalancers.List(lbClient, loadbalancers.ListOpts{}).EachPage(func(page pagination.Page) (bool, error) {
+		extracted, err := loadbalancers.ExtractLoadBalancers(page)
+		if err != nil {
+			return false, err
+		}
+		for _, lb := range extracted {
+			results = append(results, lb)
+		}
+		return true, nil
+	})
+	return
+}
+
+func (p *openStackProvider) fetchListeners(client *gophercloud.ProviderClient, eo gophercloud.EndpointOpts) (results []listeners.Listener, err error) {
+	lbClient, err := openstack.NewLoadBalancerV2(client, eo)
+	if err != nil {
+		return
+	}
+
+	err = listeners.List(lbClient, listeners.ListOpts{}).EachPage(func(page pagination.Page) (bool, error) {
+		extracted, err := listeners.ExtractListeners(page)
+		if err != nil {
+			return false, err
+		}
+		for _, listener := range extracted {
+			results = append(results, listener)
+		}
+		return true, nil
+	})
+	return
+}
+
+// Thresholds of allowed_cidrs of listeners when max_public_prefix_size is not set. A listener
+// open to more public addresses than them, e.g. 0.0.0.0/1, is not restricted.
+const (
+	defaultListenerPublicPrefixSize   = 8
+	defaultListenerPublicPrefixSizeV6 = 32
+)
+
+// listenerRule converts a listener into an ingress rule so that it can be matched with allow rules.
+func listenerRule(listener listeners.Listener) rules.SecGroupRule {
+	protocol := "tcp"
+	switch strings.ToUpper(listener.Protocol) {
+	case "UDP":
+		protocol = "udp"
+	case "SCTP":
+		protocol = "sctp"
+	}
+	return rules.SecGroupRule{
+		Direction:    "ingress",
+		Protocol:     protocol,
+		PortRangeMin: listener.ProtocolPort,
+		PortRangeMax: listener.ProtocolPort,
+	}
+}
+
+// isListenerRestricted returns true if allowed_cidrs of the listener don't expose more public
+// addresses than the threshold of security group rules, with the rules for the load balancer.
+func (checker *OpenStackSecurityGroupChecker) isListenerRestricted(lb loadbalancers.LoadBalancer, listener listeners.Listener) bool {
+	if len(listener.AllowedCIDRs) == 0 {
+		return false
+	}
+	for _, cidr := range listener.AllowedCIDRs {
+		_, prefix, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return false
+		}
+		ipv6 := prefix.IP.To4() == nil
+		maxPrefixSize := checker.publicPrefixSizeOf(checker.loadBalancerRules(lb), ipv6)
+		if maxPrefixSize == 0 {
+			maxPrefixSize = defaultListenerPublicPrefixSize
+			if ipv6 {
+				maxPrefixSize = defaultListenerPublicPrefixSizeV6
+			}
+		}
+		broad, _, err := exceedsPublicPrefixSize(prefix, maxPrefixSize, checker.Cfg.PrivateRanges)
+		if err != nil || broad || isWorldPrefix(cidr) {
+			return false
+		}
+	}
+	return true
+}
+
+// loadBalancerRules returns the active rules for the load balancer.
+func (checker *OpenStackSecurityGroupChecker) loadBalancerRules(lb loadbalancers.LoadBalancer) []Rule {
+	matched := []Rule{}
+	for _, r := range checker.activeRules() {
+		if r.LoadBalancer != "" && checker.matchProject(r, lb.ProjectID) && (matchName(r.LoadBalancer, lb.Name) || r.LoadBalancer == lb.ID) {
+			matched = append(matched, r)
+		}
+	}
+	return matched
+}
+
+// matchAllowdListener returns true if a rule for the load balancer allows the listener port.
+func (checker *OpenStackSecurityGroupChecker) matchAllowdListener(lb loadbalancers.LoadBalancer, listener listeners.Listener) bool {
+	matched := checker.loadBalancerRules(lb)
+	allowd, _ := coverPorts(matched, listenerRule(listener))
+	if allowd {
+		checker.markSuppressing(matched, listenerRule(listener))
+	}
+	return allowd
+}
+
+// findExposedLoadBalancers reports listeners of load balancers with a public VIP that are not
+// covered by allow rules.
+func (checker *OpenStackSecurityGroupChecker) findExposedLoadBalancers(inv *inventory, allowed_sg []string) ([]Finding, error) {
+	findings := []Finding{}
+	for _, lb := range inv.LoadBalancers {
+		if contain(allowed_sg, lb.ID) {
+			continue
+		}
+		vips := []string{}
+		for _, port := range inv.Ports {
+			if port.ID != lb.VipPortID {
+				continue
+			}
+			addresses, err := checker.publicAddresses(port, inv.FloatingIPs)
+			if err != nil {
+				return nil, err
+			}
+			for _, address := range addresses {
+				vips = append(vips, address.Address)
+			}
+		}
+		if len(vips) == 0 {
+			continue
+		}
+
+		exposed := []string{}
+		for _, listener := range inv.Listeners {
+			if !listenerBelongsTo(listener, lb) || !listener.AdminStateUp {
+				continue
+			}
+			if checker.isListenerRestricted(lb, listener) || checker.matchAllowdListener(lb, listener) {
+				continue
+			}
+			exposed = append(exposed, fmt.Sprintf("%s %s/%d", listener.Name, listener.Protocol, listener.ProtocolPort))
+		}
+		if len(exposed) == 0 {
+			continue
+		}
+
+		finding := checker.newFinding(CheckLoadBalancer, SeverityHigh, lb.ProjectID, ResourceLoadBalancer, lb.ID, lb.Name)
+		finding.Ports = []string{lb.VipPortID}
+		finding.addDetail("VIP", strings.Join(vips, "\n"))
+		finding.addDetail("Listeners", strings.Join(exposed, "\n"))
+		findings = append(findings, finding)
+	}
+	return findings, nil
+}
+
+func listenerBelongsTo(listener listeners.Listener, lb loadbalancers.LoadBalancer) bool {
+	for _, l := range listener.Loadbalancers {
+		if l.ID == lb.ID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gophercloud/gophercloud/openstack/loadbalancer/v2/listeners"
+	"github.com/gophercloud/gophercloud/openstack/loadbalancer/v2/loadbalancers"
+)
+
+func TestListenerRule(t *testing.T) {
+	tests := []struct {
+		protocol string
+		want     string
+	}{
+		{"TCP", "tcp"},
+		{"HTTP", "tcp"},
+		{"HTTPS", "tcp"},
+		{"TERMINATED_HTTPS", "tcp"},
+		{"UDP", "udp"},
+		{"SCTP", "sctp"},
+	}
+	for _, tt := range tests {
+		rule := listenerRule(listeners.Listener{Protocol: tt.protocol, ProtocolPort: 5000})
+		if rule.Protocol != tt.want || rule.PortRangeMin != 5000 || rule.PortRangeMax != 5000 {
+			t.Errorf("listenerRule(%s) = %s %d-%d, want %s 5000-5000", tt.protocol, rule.Protocol, rule.PortRangeMin, rule.PortRangeMax, tt.want)
+		}
+	}
+
+	allowd, _ := coverPorts([]Rule{{Port: []string{"sctp/5000"}}}, listenerRule(listeners.Listener{Protocol: "SCTP", ProtocolPort: 5000}))
+	if !allowd {
+		t.Error("sctp/5000 doesn't allow an SCTP listener on 5000")
+	}
+}
+
+func TestIsListenerRestricted(t *testing.T) {
+	lb := loadbalancers.LoadBalancer{ID: "lb", Name: "web", ProjectID: "p1"}
+	tests := []struct {
+		maxPublicPrefixSize int
+		rules               []Rule
+		cidrs               []string
+		want                bool
+	}{
+		{0, nil, nil, false},
+		{0, nil, []string{"0.0.0.0/0"}, false},
+		{0, nil, []string{"::/0"}, false},
+		{0, nil, []string{"0.0.0.0/1"}, false},
+		{0, nil, []string{"198.51.100.0/24"}, true},
+		{0, nil, []string{"10.0.0.0/8"}, true},
+		{0, nil, []string{"2001:db8::/16"}, false},
+		{0, nil, []string{"2001:db8::/48"}, true},
+		{24, nil, []string{"198.51.100.0/24"}, true},
+		{24, nil, []string{"198.51.100.0/23"}, false},
+		{24, nil, []string{"198.51.100.0/24", "0.0.0.0/1"}, false},
+		// A rule for the load balancer overrides the global threshold.
+		{24, []Rule{{Tenant: "p1", LoadBalancer: "web", MaxPublicPrefixSize: 16}}, []string{"198.51.0.0/16"}, true},
+		{24, []Rule{{Tenant: "p1", LoadBalancer: "other", MaxPublicPrefixSize: 16}}, []string{"198.51.0.0/16"}, false},
+	}
+	for _, tt := range tests {
+		checker := newTestChecker()
+		checker.Cfg.MaxPublicPrefixSize = tt.maxPublicPrefixSize
+		checker.Cfg.Rules = tt.rules
+		got := checker.isListenerRestricted(lb, listeners.Listener{AllowedCIDRs: tt.cidrs})
+		if got != tt.want {
+			t.Errorf("isListenerRestricted(%v, max %d, rules %v) = %v, want %v", tt.cidrs, tt.maxPublicPrefixSize, tt.rules, got, tt.want)
+		}
+	}
+}
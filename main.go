@@ -84,6 +84,37 @@ func main() {
 				return StartCheck(c)
 			},
 		},
+		{
+			Name:  "exposure",
+			Usage: "print ports and protocols reachable from public addresses",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "config, c",
+					Value: "config.toml",
+				},
+				cli.StringFlag{
+					Name:  "format",
+					Value: "table",
+					Usage: "output format (table or json)",
+				},
+			},
+			Action: func(c *cli.Context) error {
+				return StartExposure(c)
+			},
+		},
+		{
+			Name:  "lint",
+			Usage: "print duplicate, shadowed and mergeable rules as JSON",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "config, c",
+					Value: "config.toml",
+				},
+			},
+			Action: func(c *cli.Context) error {
+				return StartLint(c)
+			},
+		},
 	}
 
 	err := app.Run(os.Args)
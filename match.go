@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/gophercloud/gophercloud/openstack/identity/v3/projects"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/security/groups"
+)
+
+var regexpCache sync.Map
+
+// isRegexpPattern returns true if the pattern is a regular expression enclosed in slashes.
+func isRegexpPattern(pattern string) bool {
+	return len(pattern) > 2 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/")
+}
+
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if r, ok := regexpCache.Load(pattern); ok {
+		return r.(*regexp.Regexp), nil
+	}
+	r, err := regexp.Compile(pattern[1 : len(pattern)-1])
+	if err != nil {
+		return nil, err
+	}
+	regexpCache.Store(pattern, r)
+	return r, nil
+}
+
+// validatePattern returns an error if the pattern is enclosed in slashes but is not a valid
+// regular expression. Other patterns are globs, or exact names if they are not valid globs.
+func validatePattern(pattern string) error {
+	if isRegexpPattern(pattern) {
+		if _, err := compilePattern(pattern); err != nil {
+			return fmt.Errorf("invalid pattern: %s: %s", pattern, err)
+		}
+	}
+	return nil
+}
+
+// matchName matches a name with a glob (e.g. "web-*") or a regular expression enclosed in
+// slashes (e.g. "/^web-[0-9a-f]{8}$/"). A pattern always matches the same name exactly, so that
+// rules written for names containing glob meta characters (*, ? and [) keep matching them.
+func matchName(pattern, name string) bool {
+	if pattern == name {
+		return true
+	}
+	if isRegexpPattern(pattern) {
+		r, err := compilePattern(pattern)
+		return err == nil && r.MatchString(name)
+	}
+	matched, err := path.Match(pattern, name)
+	return err == nil && matched
+}
+
+func (checker *OpenStackSecurityGroupChecker) domainName(id string) string {
+	for _, d := range checker.Domains {
+		if d.ID == id {
+			return d.Name
+		}
+	}
+	return id
+}
+
+// matchProject returns true if the tenant of the rule matches the project.
+func (checker *OpenStackSecurityGroupChecker) matchProject(r Rule, projectID string) bool {
+	return checker.matchTenant(r.Tenant, projectID)
+}
+
+// matchTenant returns true if the tenant matches the project. The tenant is a project ID, a name
+// pattern, or a domain-qualified name pattern such as "Default/web-*".
+func (checker *OpenStackSecurityGroupChecker) matchTenant(tenant string, projectID string) bool {
+	if tenant == projectID {
+		return true
+	}
+	var project *projects.Project
+	for i := range checker.Projects {
+		if checker.Projects[i].ID == projectID {
+			project = &checker.Projects[i]
+		}
+	}
+	if project == nil {
+		return false
+	}
+
+	qualified := fmt.Sprintf("%s/%s", checker.domainName(project.DomainID), project.Name)
+	if isRegexpPattern(tenant) {
+		return matchName(tenant, project.Name) || matchName(tenant, qualified)
+	}
+	if i := strings.Index(tenant, "/"); i >= 0 {
+		domain, name := tenant[:i], tenant[i+1:]
+		if domain != project.DomainID && !matchName(domain, checker.domainName(project.DomainID)) {
+			return false
+		}
+		return matchName(name, project.Name)
+	}
+	return matchName(tenant, project.Name)
+}
+
+// matchGroup returns true if the rule applies to the security group, by sg_id or by a name pattern.
+func (checker *OpenStackSecurityGroupChecker) matchGroup(r Rule, sg groups.SecGroup) bool {
+	if r.SGID != "" {
+		return r.SGID == sg.ID && (r.Tenant == "" || checker.matchProject(r, sg.TenantID))
+	}
+	return r.SG != "" && checker.matchProject(r, sg.TenantID) && matchName(r.SG, sg.Name)
+}
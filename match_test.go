@@ -0,0 +1,98 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gophercloud/gophercloud/openstack/identity/v3/domains"
+	"github.com/gophercloud/gophercloud/openstack/identity/v3/projects"
+)
+
+func TestMatchName(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"web", "web", true},
+		{"web", "web-1", false},
+		{"web-*", "web-1", true},
+		{"web-*", "db-1", false},
+		{"web-?", "web-1", true},
+		{"web-?", "web-10", false},
+		{"web-[0-9]", "web-1", true},
+		{"/^web-[0-9a-f]{4}$/", "web-a1b2", true},
+		{"/^web-[0-9a-f]{4}$/", "web-a1b2c", false},
+		{"/web/", "my-web-1", true},
+		// Names with glob meta characters keep matching themselves, even invalid globs.
+		{"web*", "web*", true},
+		{"web*", "web-1", true},
+		{`web\*`, "web-1", false},
+		{"web[", "web[", true},
+		{"web[", "web", false},
+		{"/[/", "[", false},
+	}
+	for _, tt := range tests {
+		if got := matchName(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("matchName(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestValidatePattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		wantErr bool
+	}{
+		{"web", false},
+		{"web-*", false},
+		{"web[", false},
+		{"/^web-[0-9]+$/", false},
+		{"/[/", true},
+	}
+	for _, tt := range tests {
+		if err := validatePattern(tt.pattern); (err != nil) != tt.wantErr {
+			t.Errorf("validatePattern(%q) = %v, wantErr %v", tt.pattern, err, tt.wantErr)
+		}
+	}
+}
+
+func TestMatchProject(t *testing.T) {
+	checker := newTestChecker()
+	checker.Domains = []domains.Domain{
+		{ID: "default", Name: "Default"},
+		{ID: "d-ops", Name: "ops"},
+	}
+	checker.Projects = []projects.Project{
+		{ID: "p-web", Name: "web-prod", DomainID: "default"},
+		{ID: "p-ops", Name: "web-prod", DomainID: "d-ops"},
+	}
+
+	tests := []struct {
+		tenant    string
+		projectID string
+		want      bool
+	}{
+		{"p-web", "p-web", true},
+		{"p-web", "p-ops", false},
+		{"web-prod", "p-web", true},
+		{"web-prod", "p-ops", true},
+		{"web-dev", "p-web", false},
+		{"Default/web-prod", "p-web", true},
+		{"Default/web-prod", "p-ops", false},
+		{"default/web-prod", "p-web", true},
+		{"ops/web-*", "p-ops", true},
+		{"ops/web-*", "p-web", false},
+		{"*/web-prod", "p-ops", true},
+		{"web-*", "p-web", true},
+		{"db-*", "p-web", false},
+		{"/^web-(prod|stg)$/", "p-web", true},
+		{"/^ops/web-/", "p-ops", true},
+		{"/^ops/web-/", "p-web", false},
+		{"web-prod", "p-unknown", false},
+	}
+	for _, tt := range tests {
+		if got := checker.matchProject(Rule{Tenant: tt.tenant}, tt.projectID); got != tt.want {
+			t.Errorf("matchProject(%q, %q) = %v, want %v", tt.tenant, tt.projectID, got, tt.want)
+		}
+	}
+}
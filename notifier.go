@@ -0,0 +1,158 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/slack-go/slack"
+)
+
+// Notifier sends notifications to a channel such as Slack or a webhook.
+type Notifier interface {
+	Notify(n Notification) error
+}
+
+// batchNotifier is a Notifier that sends the notifications of a check at once, e.g. a single mail
+// per recipient rather than one for each kind of finding.
+type batchNotifier interface {
+	Notifier
+	NotifyAll(ns []Notification) error
+}
+
+// Notification is a warning with the findings or the digest sections it is about.
+type Notification struct {
+	Prefix   string    `json:"prefix"`
+	Suffix   string    `json:"suffix"`
+	Findings []Finding `json:"findings,omitempty"`
+	Sections []Section `json:"sections,omitempty"`
+}
+
+// Section is a titled list of items that are not findings, e.g. in the hygiene digest.
+type Section struct {
+	Title string   `json:"title"`
+	Items []string `json:"items"`
+}
+
+// Attachment renders the section as a Slack attachment.
+func (s Section) Attachment() slack.Attachment {
+	return slack.Attachment{
+		Color:  SeverityLow.color(),
+		Fields: []slack.AttachmentField{{Title: s.Title, Value: strings.Join(s.Items, "\n")}},
+	}
+}
+
+// attachments renders the findings and sections of the notification as Slack attachments.
+func (n Notification) attachments() []slack.Attachment {
+	attachments := []slack.Attachment{}
+	for _, f := range n.Findings {
+		attachments = append(attachments, f.Attachment())
+	}
+	for _, s := range n.Sections {
+		attachments = append(attachments, s.Attachment())
+	}
+	return attachments
+}
+
+// newNotifiers returns Slack notifier of the default channel if the client is given, followed by
+// the notifiers of the config.
+func newNotifiers(conf Config, slackClient *slack.Client) []Notifier {
+	notifiers := []Notifier{}
+	if slackClient != nil && conf.SlackToken != "" {
+		notifiers = append(notifiers, &slackNotifier{Client: slackClient, Channel: conf.SlackChannel, Username: conf.Username, IconEmoji: conf.IconEmoji})
+	}
+	for _, c := range conf.Notifiers {
+		switch c.Type {
+		case "slack":
+			if slackClient != nil {
+				notifiers = append(notifiers, &slackNotifier{Client: slackClient, Channel: c.Channel, Username: conf.Username, IconEmoji: conf.IconEmoji})
+			}
+		case "webhook":
+			notifiers = append(notifiers, &webhookNotifier{URL: c.URL, Secret: c.Secret})
+		case "mattermost":
+			notifiers = append(notifiers, &mattermostNotifier{URL: c.URL, Channel: c.Channel, Username: conf.Username, IconEmoji: conf.IconEmoji})
+		case "teams":
+			notifiers = append(notifiers, &teamsNotifier{URL: c.URL})
+		case "email":
+			notifiers = append(notifiers, &emailNotifier{Cfg: c.SMTP})
+		}
+	}
+	return notifiers
+}
+
+// notify sends the notification to every notifier, even if some of them fail. While Run queues
+// notifications, batch notifiers receive them at flushNotifications instead.
+func (checker *OpenStackSecurityGroupChecker) notify(n Notification) error {
+	var result error
+	for _, notifier := range checker.Notifiers {
+		if _, ok := notifier.(batchNotifier); ok && checker.pending != nil {
+			continue
+		}
+		if err := notifier.Notify(n); err != nil {
+			logrus.Errorf("Failed to notify with %T: %s", notifier, err)
+			if result == nil {
+				result = err
+			}
+		}
+	}
+	if checker.pending != nil {
+		checker.pending = append(checker.pending, n)
+	}
+	return result
+}
+
+// flushNotifications sends the notifications queued during Run to the batch notifiers.
+func (checker *OpenStackSecurityGroupChecker) flushNotifications() error {
+	pending := checker.pending
+	checker.pending = nil
+	if len(pending) == 0 {
+		return nil
+	}
+	var result error
+	for _, notifier := range checker.Notifiers {
+		batch, ok := notifier.(batchNotifier)
+		if !ok {
+			continue
+		}
+		if err := batch.NotifyAll(pending); err != nil {
+			logrus.Errorf("Failed to notify with %T: %s", notifier, err)
+			if result == nil {
+				result = err
+			}
+		}
+	}
+	return result
+}
+
+// slackNotifier posts the prefix, each finding and the suffix as separate messages. The server
+// reads the "ID" field of a message to allow the resource when a reaction is added.
+type slackNotifier struct {
+	Client    *slack.Client
+	Channel   string
+	Username  string
+	IconEmoji string
+}
+
+func (s *slackNotifier) Notify(n Notification) error {
+	params := slack.PostMessageParameters{
+		Username:  s.Username,
+		IconEmoji: s.IconEmoji,
+	}
+	err := postMessage(s.Client, s.Channel, n.Prefix, nil, params)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to post prefix message")
+	}
+
+	for _, item := range n.attachments() {
+		err = postMessage(s.Client, s.Channel, "", []slack.Attachment{item}, params)
+		if err != nil {
+			return errors.Wrapf(err, "Failed to post attachments")
+		}
+	}
+	err = postMessage(s.Client, s.Channel, n.Suffix, nil, params)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to post suffix message")
+	}
+
+	return nil
+}
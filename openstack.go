@@ -7,21 +7,27 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"math/big"
 	"net"
 	"net/http"
 	"os"
-	"regexp"
-	"strconv"
+	"sync"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/gophercloud/gophercloud"
 	"github.com/gophercloud/gophercloud/openstack"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/servers"
+	"github.com/gophercloud/gophercloud/openstack/identity/v3/domains"
 	"github.com/gophercloud/gophercloud/openstack/identity/v3/projects"
+	"github.com/gophercloud/gophercloud/openstack/loadbalancer/v2/listeners"
+	"github.com/gophercloud/gophercloud/openstack/loadbalancer/v2/loadbalancers"
 	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/layer3/floatingips"
 	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/security/groups"
 	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/security/rules"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/networks"
 	"github.com/gophercloud/gophercloud/openstack/networking/v2/ports"
 	"github.com/gophercloud/gophercloud/pagination"
+	"github.com/open-policy-agent/opa/loader"
 	"github.com/open-policy-agent/opa/rego"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
@@ -31,18 +37,30 @@ import (
 const REDIS_KEY = "allowed_sg"
 
 type OpenStackSecurityGroupChecker struct {
-	Cfg         Config
-	SlackClient *slack.Client
-	AuthOptions gophercloud.AuthOptions
-	RegionName  string
-	CACert      string
-	Cert        string
-	Key         string
-	Attachments []slack.Attachment
-	Projects    []projects.Project
+	Cfg       Config
+	Notifiers []Notifier
+	Provider  Provider
+	Projects  []projects.Project
+	Domains   []domains.Domain
+
+	internalNetworkIDs []string
+	externalNetworkIDs []string
+	servers            map[string]servers.Server
+	// suppressing collects indexes of the allow rules that suppress a finding during Run, and
+	// suppressed keeps them of the last completed Run for the allowlist hygiene report.
+	suppressing map[int]bool
+	suppressed  map[int]bool
+	// pending queues the notifications of Run for batch notifiers, which are sent at its end.
+	pending []Notification
+	// mu serializes Run and RunHygiene, which are scheduled independently by cron.
+	mu sync.Mutex
 }
 
-func (checker *OpenStackSecurityGroupChecker) Run() (err error) {
+// Run executes the checks, posts warnings for the findings and returns them.
+func (checker *OpenStackSecurityGroupChecker) Run() ([]Finding, error) {
+	checker.mu.Lock()
+	defer checker.mu.Unlock()
+
 	redisURL := "localhost:6379"
 
 	if os.Getenv("REDIS_URL") != "" {
@@ -54,77 +72,105 @@ func (checker *OpenStackSecurityGroupChecker) Run() (err error) {
 			Password: "",
 			DB:       0,
 		})
-	len, err := redisClient.LLen(context.Background(), REDIS_KEY).Result()
+	length, err := redisClient.LLen(context.Background(), REDIS_KEY).Result()
 	if err != nil {
-		return err
+		return nil, err
 	}
-	allowed_sg, err := redisClient.LRange(context.Background(), REDIS_KEY, 0, len).Result()
+	allowed_sg, err := redisClient.LRange(context.Background(), REDIS_KEY, 0, length).Result()
 	if err != nil {
-		return err
+		return nil, err
 	}
 	logrus.Infof("Temporary allowed security groups: %+v\n", allowed_sg)
 
-	existNoguardSG := false
-	eo := gophercloud.EndpointOpts{Region: checker.RegionName}
-	client, err := checker.authenticate(checker.AuthOptions, checker.CACert, checker.Cert, checker.Key)
+	inv, err := checker.fetchInventory()
 	if err != nil {
-		return errors.Wrapf(err, "Failed to authenticate OpenStack API")
+		return nil, err
 	}
+	ports, fips, securityGroups := inv.Ports, inv.FloatingIPs, inv.SecurityGroups
+	checker.suppressing = map[int]bool{}
+	checker.pending = []Notification{}
+	defer func() { checker.pending = nil }()
 
-	checker.Projects, err = checker.fetchProjects(client, eo)
-	if err != nil {
-		return errors.Wrapf(err, "Failed to fetch projects")
-	}
+	allFindings := []Finding{}
 
-	for i, rule := range checker.Cfg.Rules {
-		for _, p := range checker.Projects {
-			if rule.Tenant == p.Name {
-				checker.Cfg.Rules[i].TenantID = p.ID
-			}
+	logrus.Info("Start to find security group is allowed to access from any.")
+
+	findings := []Finding{}
+	for _, sg := range securityGroups {
+		found, err := checker.isFullOpen(sg, ports, fips, allowed_sg)
+		if err != nil {
+			return nil, err
 		}
-	}
-	ports, err := checker.fetchPorts(client, eo)
-	if err != nil {
-		return errors.Wrapf(err, "Failed to fetch ports")
+		findings = append(findings, found...)
 	}
 
-	fips, err := checker.fetchFloatingIPS(client, eo)
-	if err != nil {
-		return errors.Wrapf(err, "Failed to fetch fips")
+	if checker.Cfg.PortSecurityCheck {
+		found, err := checker.findPortSecurityDisabled(ports, fips, allowed_sg)
+		if err != nil {
+			return nil, err
+		}
+		findings = append(findings, found...)
 	}
 
-	securityGroups, err := checker.fetchSecurityGroups(client, eo)
-	if err != nil {
-		return errors.Wrapf(err, "Failed to security groups")
+	if checker.Cfg.AddressPairCheck {
+		found, err := checker.findBroadAddressPairs(ports, fips, allowed_sg)
+		if err != nil {
+			return nil, err
+		}
+		findings = append(findings, found...)
 	}
 
-	logrus.Info("Start to find security group is allowed to access from any.")
-
-	for _, sg := range securityGroups {
-		isFullOpen, err := checker.isFullOpen(sg, ports, fips, allowed_sg)
+	if checker.Cfg.LoadBalancerCheck {
+		found, err := checker.findExposedLoadBalancers(inv, allowed_sg)
 		if err != nil {
-			return err
-		}
-		if isFullOpen {
-			existNoguardSG = true
+			return nil, err
 		}
+		findings = append(findings, found...)
 	}
 
-	if existNoguardSG {
-		if !checker.Cfg.DryRun {
-			err := checker.postWarning(checker.Attachments, checker.Cfg.PrefixMessage, checker.Cfg.SuffixMessage)
-			if err != nil {
-				return errors.Wrapf(err, "Failed to post warning")
-			}
+	if checker.Cfg.TransitiveExposure {
+		found, err := checker.findTransitiveExposure(securityGroups, ports, fips, allowed_sg)
+		if err != nil {
+			return nil, err
 		}
+		findings = append(findings, found...)
+	}
 
+	findings, err = checker.recordFirstSeen(context.Background(), redisClient, findings)
+	if err != nil {
+		return nil, err
+	}
+	if len(findings) > 0 {
+		if err := checker.reportFindings(findings, checker.Cfg.PrefixMessage, checker.Cfg.SuffixMessage); err != nil {
+			return nil, err
+		}
 		logrus.Info("Security group that allowed to access from any is found.")
-
 	} else {
 		logrus.Info("No security group that allowed to access from any is found.")
 	}
+	allFindings = append(allFindings, findings...)
 
-	checker.Attachments = []slack.Attachment{}
+	if checker.Cfg.Egress.Enabled {
+		logrus.Info("Start to find security group that allows unrestricted egress.")
+
+		findings, err := checker.findUnrestrictedEgress(securityGroups, ports, allowed_sg)
+		if err != nil {
+			return nil, err
+		}
+		findings, err = checker.recordFirstSeen(context.Background(), redisClient, findings)
+		if err != nil {
+			return nil, err
+		}
+		if len(findings) > 0 {
+			if err := checker.reportFindings(findings, checker.Cfg.Egress.PrefixMessage, checker.Cfg.Egress.SuffixMessage); err != nil {
+				return nil, err
+			}
+			logrus.Info("Security group that allows unrestricted egress is found.")
+		} else {
+			logrus.Info("No security group that allows unrestricted egress is found.")
+		}
+		allFindings = append(allFindings, findings...)
+	}
 
 	logrus.Info("Start to find security group don't match policy.")
 
@@ -136,83 +182,219 @@ func (checker *OpenStackSecurityGroupChecker) Run() (err error) {
 		if policy.Data != "" {
 			paths = append(paths, policy.Data)
 		}
-		r := rego.New(
+		loaded, err := loader.All(paths)
+		if err != nil {
+			return nil, err
+		}
+		if len(checker.Cfg.CIDRSets) > 0 {
+			loaded.Documents["cidr_sets"] = cidrSetsDocument(checker.Cfg.CIDRSets)
+		}
+		store, err := loaded.Store()
+		if err != nil {
+			return nil, err
+		}
+		options := []func(*rego.Rego){
 			rego.Query("x = data.example.allow"),
-			rego.Load(paths, nil),
-		)
+			rego.Store(store),
+		}
+		for _, module := range loaded.ParsedModules() {
+			options = append(options, rego.ParsedModule(module))
+		}
+		r := rego.New(options...)
 
 		query, err := r.PrepareForEval(context.Background())
 		if err != nil {
-			return err
+			return nil, err
 		}
-		existsSGMatchedPolicy := false
-		for _, sg := range securityGroups {
-			if contain(allowed_sg, sg.ID) {
-				logrus.Info("許可済みのSGなのでSlackに警告メッセージは流さない")
-				continue
-			}
-			match, err := checker.matchPolicy(query, sg)
-			if err != nil {
-				return err
+		findings := []Finding{}
+		if policy.Resource == ResourcePort {
+			for _, port := range ports {
+				if contain(allowed_sg, port.ID) {
+					continue
+				}
+				finding, err := checker.matchPortPolicy(query, policy, port, fips)
+				if err != nil {
+					return nil, err
+				}
+				if finding != nil {
+					findings = append(findings, *finding)
+				}
 			}
-			if match {
-				existsSGMatchedPolicy = true
+		} else {
+			for _, sg := range securityGroups {
+				if contain(allowed_sg, sg.ID) {
+					logrus.Info("許可済みのSGなのでSlackに警告メッセージは流さない")
+					continue
+				}
+				finding, err := checker.matchPolicy(query, policy, sg, ports, fips)
+				if err != nil {
+					return nil, err
+				}
+				if finding != nil {
+					findings = append(findings, *finding)
+				}
 			}
 		}
+		findings, err = checker.recordFirstSeen(context.Background(), redisClient, findings)
+		if err != nil {
+			return nil, err
+		}
 
-		if existsSGMatchedPolicy {
-			if !checker.Cfg.DryRun {
-				err := checker.postWarning(checker.Attachments, policy.PrefixMessage, policy.SuffixMessage)
-				if err != nil {
-					return errors.Wrapf(err, "Failed to post warning")
-				}
+		if len(findings) > 0 {
+			if err := checker.reportFindings(findings, policy.PrefixMessage, policy.SuffixMessage); err != nil {
+				return nil, err
 			}
 			logrus.Info("Security group that match policy is found.")
 		} else {
 			logrus.Info("No security group that match policy is found.")
 		}
+		allFindings = append(allFindings, findings...)
+	}
+
+	if err := checker.pruneFirstSeen(context.Background(), redisClient, allFindings); err != nil {
+		return nil, err
+	}
+	if err := checker.flushNotifications(); err != nil {
+		return nil, errors.Wrapf(err, "Failed to post warning")
+	}
+
+	checker.suppressed, checker.suppressing = checker.suppressing, nil
+	checker.logAllowlistReport(checker.allowlistHygiene(inv))
+	return allFindings, nil
+}
+
+// reportFindings prints allow rules for the findings and sends them to the notifiers unless dry run.
+func (checker *OpenStackSecurityGroupChecker) reportFindings(findings []Finding, prefix string, suffix string) error {
+	for _, f := range findings {
+		f.Print()
+	}
+	if checker.Cfg.DryRun {
+		return nil
+	}
+	if err := checker.notify(Notification{Prefix: prefix, Suffix: suffix, Findings: findings}); err != nil {
+		return errors.Wrapf(err, "Failed to post warning")
 	}
 	return nil
 }
 
-func contain(s []string, e string) bool {
-	for _, a := range s {
-		if a == e {
-			return true
+// inventory is the set of resources fetched from a provider for a check.
+type inventory struct {
+	Projects       []projects.Project
+	Domains        []domains.Domain
+	Networks       []networks.Network
+	Servers        []servers.Server
+	Ports          []neutronPort
+	FloatingIPs    []floatingips.FloatingIP
+	SecurityGroups []groups.SecGroup
+	LoadBalancers  []loadbalancers.LoadBalancer
+	Listeners      []listeners.Listener
+}
+
+// fetchInventory fetches the resources used by the checks from the provider.
+// It also resolves tenant IDs of the rules and the internal/external networks.
+func (checker *OpenStackSecurityGroupChecker) fetchInventory() (*inventory, error) {
+	inv, err := checker.Provider.Fetch()
+	if err != nil {
+		return nil, err
+	}
+	checker.Projects = inv.Projects
+	checker.Domains = inv.Domains
+
+	for i, rule := range checker.Cfg.Rules {
+		checker.Cfg.Rules[i].TenantID = ""
+		for _, p := range checker.Projects {
+			if checker.matchProject(rule, p.ID) {
+				checker.Cfg.Rules[i].TenantID = p.ID
+				break
+			}
 		}
 	}
-	return false
+	checker.internalNetworkIDs = resolveNetworkIDs(checker.Cfg.InternalNetworks, inv.Networks)
+	checker.externalNetworkIDs = resolveNetworkIDs(checker.Cfg.ExternalNetworks, inv.Networks)
+
+	checker.servers = map[string]servers.Server{}
+	for _, server := range inv.Servers {
+		checker.servers[server.ID] = server
+	}
+
+	return inv, nil
+}
+
+// openStackProvider fetches resources from Keystone, Neutron, Nova and Octavia.
+type openStackProvider struct {
+	AuthOptions gophercloud.AuthOptions
+	RegionName  string
+	CACert      string
+	Cert        string
+	Key         string
+	// LoadBalancers enables fetching load balancers and listeners.
+	LoadBalancers bool
 }
 
-func (checker *OpenStackSecurityGroupChecker) postWarning(attachments []slack.Attachment, prefix string, suffix string) error {
-	params := slack.PostMessageParameters{
-		Username:  checker.Cfg.Username,
-		IconEmoji: checker.Cfg.IconEmoji,
+func (p *openStackProvider) Fetch() (*inventory, error) {
+	eo := gophercloud.EndpointOpts{Region: p.RegionName}
+	client, err := p.authenticate(p.AuthOptions, p.CACert, p.Cert, p.Key)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to authenticate OpenStack API")
 	}
-	err := postMessage(checker.SlackClient, checker.Cfg.SlackChannel, prefix, nil, params)
+	inv := &inventory{}
+
+	inv.Projects, err = p.fetchProjects(client, eo)
 	if err != nil {
-		return errors.Wrapf(err, "Failed to post prefix message")
+		return nil, errors.Wrapf(err, "Failed to fetch projects")
 	}
 
-	for _, item := range attachments {
-		params := slack.PostMessageParameters{
-			Username:  checker.Cfg.Username,
-			IconEmoji: checker.Cfg.IconEmoji,
-		}
-		attachments := []slack.Attachment{
-			item,
+	inv.Domains, err = p.fetchDomains(client, eo)
+	if err != nil {
+		logrus.Warnf("Failed to fetch domains, domain-qualified tenants only match domain IDs: %s", err)
+	}
+
+	inv.Networks, err = p.fetchNetworks(client, eo)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to fetch networks")
+	}
+
+	inv.Ports, err = p.fetchPorts(client, eo)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to fetch ports")
+	}
+
+	inv.FloatingIPs, err = p.fetchFloatingIPS(client, eo)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to fetch fips")
+	}
+
+	inv.SecurityGroups, err = p.fetchSecurityGroups(client, eo)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to security groups")
+	}
+
+	if p.LoadBalancers {
+		inv.LoadBalancers, err = p.fetchLoadBalancers(client, eo)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to fetch load balancers")
 		}
-		err = postMessage(checker.SlackClient, checker.Cfg.SlackChannel, "", attachments, params)
+		inv.Listeners, err = p.fetchListeners(client, eo)
 		if err != nil {
-			return errors.Wrapf(err, "Failed to post attachments")
+			return nil, errors.Wrapf(err, "Failed to fetch listeners")
 		}
 	}
-	err = postMessage(checker.SlackClient, checker.Cfg.SlackChannel, suffix, nil, params)
+
+	inv.Servers, err = p.fetchServers(client, eo)
 	if err != nil {
-		return errors.Wrapf(err, "Failed to post suffix message")
+		logrus.Warnf("Failed to fetch servers, findings are not enriched with instances: %s", err)
 	}
 
-	return nil
+	return inv, nil
+}
+
+func contain(s []string, e string) bool {
+	for _, a := range s {
+		if a == e {
+			return true
+		}
+	}
+	return false
 }
 
 func postMessage(api *slack.Client, channel string, text string, attachments []slack.Attachment, params slack.PostMessageParameters) error {
@@ -223,6 +405,13 @@ func postMessage(api *slack.Client, channel string, text string, attachments []s
 	return nil
 }
 
+func overlapOrNone(name string) string {
+	if name == "" {
+		return "none"
+	}
+	return name
+}
+
 func getProjectNameFromID(id string, ps []projects.Project) (string, error) {
 	for _, p := range ps {
 		if p.ID == id {
@@ -232,35 +421,59 @@ func getProjectNameFromID(id string, ps []projects.Project) (string, error) {
 	return "", fmt.Errorf("Not found project: %s", id)
 }
 
-func matchAllowdRule(allowdRules []Rule, sg groups.SecGroup, rule rules.SecGroupRule) bool {
+// matchAllowdRule returns true if the rule is fully covered by allowdRules.
+// For protocols with ports, it also returns the sub-ranges of the rule that are not allowed.
+func (checker *OpenStackSecurityGroupChecker) matchAllowdRule(allowdRules []Rule, sg groups.SecGroup, rule rules.SecGroupRule) (bool, portRanges) {
+	matched := []Rule{}
 	for _, allowdRule := range allowdRules {
-		if allowdRule.TenantID == sg.TenantID && allowdRule.SG == sg.Name {
-			r := regexp.MustCompile(`(\d*)-(\d*)`)
-			for _, port := range allowdRule.Port {
-				if r.MatchString(port) {
-					result := r.FindAllStringSubmatch(port, -1)
-					if result[0][1] == strconv.Itoa(rule.PortRangeMin) && result[0][2] == strconv.Itoa(rule.PortRangeMax) {
-						return true
-					}
-				}
-			}
-			if contains(allowdRule.Port, strconv.Itoa(rule.PortRangeMin)) && contains(allowdRule.Port, strconv.Itoa(rule.PortRangeMax)) {
-				return true
+		if !checker.matchGroup(allowdRule, sg) || allowdRule.direction() != rule.Direction {
+			continue
+		}
+		if len(allowdRule.Sources) > 0 {
+			_, prefix, err := net.ParseCIDR(effectiveSource(rule).Prefix)
+			if err != nil || !checker.containedIn(allowdRule.Sources, prefix) {
+				continue
 			}
 		}
+		matched = append(matched, allowdRule)
 	}
-	return false
+	allowd, uncovered := coverPorts(matched, rule)
+	if allowd {
+		checker.markSuppressing(matched, rule)
+	}
+	return allowd, uncovered
 }
 
-func contains(slice []string, item string) bool {
-	for _, a := range slice {
-		if a == item {
-			return true
+// coverPorts returns true if the ports of the rule are fully covered by the ports of allowdRules.
+func coverPorts(allowdRules []Rule, rule rules.SecGroupRule) (bool, portRanges) {
+	protocol := normalizeProtocol(rule.Protocol)
+	allowdPorts := portRanges{}
+	for _, allowdRule := range allowdRules {
+		for _, entry := range allowdRule.Port {
+			p, ports := parseAllowedPort(entry)
+			if p != protocol {
+				continue
+			}
+			if ports == "" || !protocolHasPorts(p) {
+				return true, nil
+			}
+			ranges, err := parsePortRanges(ports)
+			if err != nil {
+				logrus.Warnf("Ignore invalid port in rule (tenant: %s, sg: %s): %s", allowdRule.Tenant, allowdRule.SG, err)
+				continue
+			}
+			allowdPorts = append(allowdPorts, ranges...)
 		}
 	}
-	return false
+
+	if !protocolHasPorts(protocol) {
+		return false, nil
+	}
+	uncovered := allowdPorts.uncovered(rulePortRange(rule))
+	return len(uncovered) == 0, uncovered
 }
-func (checker *OpenStackSecurityGroupChecker) authenticate(opts gophercloud.AuthOptions, caCert string, osCert string, osKey string) (*gophercloud.ProviderClient, error) {
+
+func (p *openStackProvider) authenticate(opts gophercloud.AuthOptions, caCert string, osCert string, osKey string) (*gophercloud.ProviderClient, error) {
 	client, err := openstack.NewClient(opts.IdentityEndpoint)
 	if err != nil {
 		return nil, err
@@ -306,7 +519,7 @@ func (checker *OpenStackSecurityGroupChecker) authenticate(opts gophercloud.Auth
 	return client, nil
 }
 
-func (checker *OpenStackSecurityGroupChecker) fetchProjects(client *gophercloud.ProviderClient, eo gophercloud.EndpointOpts) (results []projects.Project, err error) {
+func (p *openStackProvider) fetchProjects(client *gophercloud.ProviderClient, eo gophercloud.EndpointOpts) (results []projects.Project, err error) {
 	identityClient, err := openstack.NewIdentityV3(client, eo)
 	if err != nil {
 		return
@@ -325,7 +538,26 @@ func (checker *OpenStackSecurityGroupChecker) fetchProjects(client *gophercloud.
 	return
 }
 
-func (checker *OpenStackSecurityGroupChecker) fetchSecurityGroups(client *gophercloud.ProviderClient, eo gophercloud.EndpointOpts) (results []groups.SecGroup, err error) {
+func (p *openStackProvider) fetchDomains(client *gophercloud.ProviderClient, eo gophercloud.EndpointOpts) (results []domains.Domain, err error) {
+	identityClient, err := openstack.NewIdentityV3(client, eo)
+	if err != nil {
+		return
+	}
+
+	err = domains.List(identityClient, nil).EachPage(func(page pagination.Page) (bool, error) {
+		extracted, err := domains.ExtractDomains(page)
+		if err != nil {
+			return false, err
+		}
+		for _, domain := range extracted {
+			results = append(results, domain)
+		}
+		return true, nil
+	})
+	return
+}
+
+func (p *openStackProvider) fetchSecurityGroups(client *gophercloud.ProviderClient, eo gophercloud.EndpointOpts) (results []groups.SecGroup, err error) {
 	networkClient, err := openstack.NewNetworkV2(client, eo)
 	if err != nil {
 		return
@@ -344,18 +576,19 @@ func (checker *OpenStackSecurityGroupChecker) fetchSecurityGroups(client *gopher
 	return
 }
 
-func (checker *OpenStackSecurityGroupChecker) fetchPorts(client *gophercloud.ProviderClient, eo gophercloud.EndpointOpts) (results []ports.Port, err error) {
+func (p *openStackProvider) fetchPorts(client *gophercloud.ProviderClient, eo gophercloud.EndpointOpts) (results []neutronPort, err error) {
 	networkClient, err := openstack.NewNetworkV2(client, eo)
 	if err != nil {
 		return
 	}
 
 	ports.List(networkClient, ports.ListOpts{}).EachPage(func(page pagination.Page) (bool, error) {
-		ports, err := ports.ExtractPorts(page)
+		var extracted []neutronPort
+		err := ports.ExtractPortsInto(page, &extracted)
 		if err != nil {
 			return false, err
 		}
-		for _, port := range ports {
+		for _, port := range extracted {
 			results = append(results, port)
 		}
 		return true, nil
@@ -363,7 +596,37 @@ func (checker *OpenStackSecurityGroupChecker) fetchPorts(client *gophercloud.Pro
 	return
 }
 
-func (checker *OpenStackSecurityGroupChecker) fetchFloatingIPS(client *gophercloud.ProviderClient, eo gophercloud.EndpointOpts) (results []floatingips.FloatingIP, err error) {
+func (p *openStackProvider) fetchNetworks(client *gophercloud.ProviderClient, eo gophercloud.EndpointOpts) (results []networks.Network, err error) {
+	networkClient, err := openstack.NewNetworkV2(client, eo)
+	if err != nil {
+		return
+	}
+
+	err = networks.List(networkClient, networks.ListOpts{}).EachPage(func(page pagination.Page) (bool, error) {
+		extracted, err := networks.ExtractNetworks(page)
+		if err != nil {
+			return false, err
+		}
+		for _, network := range extracted {
+			results = append(results, network)
+		}
+		return true, nil
+	})
+	return
+}
+
+// resolveNetworkIDs returns IDs of the networks whose ID or name is in names.
+func resolveNetworkIDs(names []string, ns []networks.Network) []string {
+	ids := []string{}
+	for _, n := range ns {
+		if contain(names, n.ID) || contain(names, n.Name) {
+			ids = append(ids, n.ID)
+		}
+	}
+	return ids
+}
+
+func (p *openStackProvider) fetchFloatingIPS(client *gophercloud.ProviderClient, eo gophercloud.EndpointOpts) (results []floatingips.FloatingIP, err error) {
 	networkClient, err := openstack.NewNetworkV2(client, eo)
 	if err != nil {
 		return
@@ -382,161 +645,185 @@ func (checker *OpenStackSecurityGroupChecker) fetchFloatingIPS(client *gopherclo
 	return
 }
 
-func (checker *OpenStackSecurityGroupChecker) isFullOpen(sg groups.SecGroup, ports []ports.Port, fips []floatingips.FloatingIP, allowed_sg []string) (bool, error) {
-	isFullOpen := false
-
-	ignorePort := true
-IGNOREPORT:
-	for _, port := range ports {
-		for _, sgid := range port.SecurityGroups {
-			if sgid == sg.ID {
-				// FIPがバインドされているならば通知対象にする
-				for _, fip := range fips {
-					if fip.PortID == port.ID {
-						ignorePort = false
-						break IGNOREPORT
-					}
-				}
+func (checker *OpenStackSecurityGroupChecker) isFullOpen(sg groups.SecGroup, ports []neutronPort, fips []floatingips.FloatingIP, allowed_sg []string) ([]Finding, error) {
+	findings := []Finding{}
 
-				// パブリックIPを持つポートならば通知対象にする
-				for _, ip := range port.FixedIPs {
-					isPrivate, err := isPrivateIP(net.ParseIP(ip.IPAddress))
-					if err != nil {
-						return false, err
-					}
-					if !isPrivate {
-						ignorePort = false
-						break IGNOREPORT
-
-					}
-				}
-			}
+	attached, _, err := checker.publicPortsOf(sg, ports, fips, "")
+	if err != nil {
+		return nil, err
+	}
+	if len(attached) == 0 {
+		return findings, nil
+	}
+
+	for _, rule := range sg.Rules {
+		if rule.Direction != "ingress" {
+			continue
+		}
+		source := effectiveSource(rule)
+		broad, exposed, err := checker.isBroadSource(sg, source)
+		if err != nil {
+			return nil, err
+		}
+		untrusted, overlap, err := checker.isUntrustedSource(sg, source)
+		if err != nil {
+			return nil, err
+		}
+		if !source.IsWorld() && !broad && !untrusted {
+			continue
+		}
+
+		// An IPv4 rule doesn't expose IPv6 addresses and vice versa.
+		publicPorts, ips, err := checker.publicPortsOf(sg, ports, fips, ruleEtherType(rule))
+		if err != nil {
+			return nil, err
 		}
+		if len(publicPorts) == 0 {
+			continue
+		}
+
+		allowd, uncovered := checker.matchAllowdRule(checker.activeRules(), sg, rule)
+		if allowd {
+			continue
+		}
+		if contain(allowed_sg, sg.ID) {
+			logrus.Info("許可済みのSGなのでSlackに警告メッセージは流さない")
+			continue
+		}
+
+		severity := SeverityMedium
+		if source.IsWorld() {
+			severity = SeverityHigh
+		}
+		finding := checker.newFinding(CheckWorldOpen, severity, sg.TenantID, ResourceSecurityGroup, sg.ID, sg.Name)
+		finding.Rule = newFindingRule(rule)
+		finding.Ports = publicPorts
+		finding.IPs = ips
+		if broad {
+			finding.addDetail("PublicAddresses", exposed.String())
+		}
+		instances, err := checker.instancesOf(sg, ports, fips)
+		if err != nil {
+			return nil, err
+		}
+		if len(instances) > 0 {
+			finding.addDetail("Instances", formatInstances(instances))
+		}
+		if untrusted {
+			finding.addDetail("TrustedSetOverlap", overlapOrNone(overlap))
+		}
+		if len(uncovered) > 0 && uncovered.String() != rulePortRange(rule).String() {
+			finding.addDetail("Uncovered", uncovered.String())
+		}
+		findings = append(findings, finding)
 	}
 
-	if ignorePort {
-		return false, nil
+	return findings, nil
+}
+
+// isPublicPort returns true if the port is reachable from the internet, i.e. a floating IP is bound
+// to it or one of its fixed IPs is public.
+func (checker *OpenStackSecurityGroupChecker) isPublicPort(port neutronPort, fips []floatingips.FloatingIP) (bool, error) {
+	addresses, err := checker.publicAddresses(port, fips)
+	if err != nil {
+		return false, err
 	}
+	return len(addresses) > 0, nil
+}
 
-	for _, rule := range sg.Rules {
-		if rule.RemoteIPPrefix == "0.0.0.0/0" && rule.Protocol == "tcp" && rule.Direction == "ingress" {
-			if !matchAllowdRule(checker.Cfg.Rules, sg, rule) {
-				if contain(allowed_sg, sg.ID) {
-					logrus.Info("許可済みのSGなのでSlackに警告メッセージは流さない")
-					continue
-				}
+// isBroadSource returns true if the source exposes more public addresses than allowed by
+// max_public_prefix_size, along with the number of exposed public addresses.
+func (checker *OpenStackSecurityGroupChecker) isBroadSource(sg groups.SecGroup, source ruleSource) (bool, *big.Int, error) {
+	if source.RemoteGroupID != "" {
+		return false, nil, nil
+	}
+	_, prefix, err := net.ParseCIDR(source.Prefix)
+	if err != nil {
+		logrus.Warnf("Ignore invalid remote ip prefix (sg: %s): %s", sg.ID, source.Prefix)
+		return false, nil, nil
+	}
+	return exceedsPublicPrefixSize(prefix, checker.maxPublicPrefixSize(sg, prefix.IP.To4() == nil), checker.Cfg.PrivateRanges)
+}
 
-				isFullOpen = true
-				projectName, err := getProjectNameFromID(sg.TenantID, checker.Projects)
-				if err != nil {
-					projectName = sg.TenantID
-					//return isFullOpen, errors.Wrapf(err, "Failed to get project name from id (%s)", sg.TenantID)
-				}
-				fmt.Printf("[[rules]]\n")
-				fmt.Printf("tenant = \"%s\"\n", projectName)
-				fmt.Printf("sg = \"%s\"\n", sg.Name)
-
-				fields := []slack.AttachmentField{
-					{Title: "Tenant", Value: projectName},
-					{Title: "ID", Value: sg.ID},
-					{Title: "Name", Value: sg.Name},
-					{Title: "PortRange", Value: fmt.Sprintf("%d-%d", rule.PortRangeMin, rule.PortRangeMax)},
-				}
-				attachment := slack.Attachment{
-					Color:  "#ff6347",
-					Fields: fields,
-				}
-				checker.Attachments = append(checker.Attachments, attachment)
-			}
+// maxPublicPrefixSize returns the threshold for the security group. A value in a matching rule
+// takes precedence over the global one.
+func (checker *OpenStackSecurityGroupChecker) maxPublicPrefixSize(sg groups.SecGroup, ipv6 bool) int {
+	matched := []Rule{}
+	for _, r := range checker.activeRules() {
+		if checker.matchGroup(r, sg) && r.direction() == "ingress" {
+			matched = append(matched, r)
 		}
 	}
+	return checker.publicPrefixSizeOf(matched, ipv6)
+}
 
-	return isFullOpen, nil
+// publicPrefixSizeOf returns the threshold of the global config overridden by the rules.
+func (checker *OpenStackSecurityGroupChecker) publicPrefixSizeOf(matched []Rule, ipv6 bool) int {
+	size := checker.Cfg.MaxPublicPrefixSize
+	if ipv6 {
+		size = checker.Cfg.MaxPublicPrefixSizeV6
+	}
+	for _, r := range matched {
+		if !ipv6 && r.MaxPublicPrefixSize > 0 {
+			size = r.MaxPublicPrefixSize
+		}
+		if ipv6 && r.MaxPublicPrefixSizeV6 > 0 {
+			size = r.MaxPublicPrefixSizeV6
+		}
+	}
+	return size
 }
 
-func (checker *OpenStackSecurityGroupChecker) matchPolicy(query rego.PreparedEvalQuery, sg groups.SecGroup) (bool, error) {
-	match := false
-	ctx := context.Background()
-	var input interface{}
+// matchPolicy returns a finding if the policy matches the security group, or nil.
+func (checker *OpenStackSecurityGroupChecker) matchPolicy(query rego.PreparedEvalQuery, policy Policy, sg groups.SecGroup, ports []neutronPort, fips []floatingips.FloatingIP) (*Finding, error) {
 	var s struct {
 		groups.SecGroup
-		CreatedAt int64 `json:"created_at"`
+		CreatedAt int64          `json:"created_at"`
+		Instances []instanceInfo `json:"instances"`
 	}
-	s.SecGroup = sg
-	s.CreatedAt = sg.CreatedAt.UnixNano()
-	jsonData := []byte{}
-	jsonData, err := json.Marshal(&s)
+	instances, err := checker.instancesOf(sg, ports, fips)
 	if err != nil {
-		return match, err
-	}
-	err = json.Unmarshal(jsonData, &input)
-	if err != nil {
-		return match, err
+		return nil, err
 	}
+	s.SecGroup = sg
+	s.CreatedAt = sg.CreatedAt.UnixNano()
+	s.Instances = instances
 
-	rs, err := query.Eval(ctx, rego.EvalInput(input))
+	matched, err := evalPolicy(query, &s)
 	if err != nil {
-		return match, err
+		return nil, err
 	}
-	if len(rs) > 0 && rs[0].Bindings["x"].(bool) {
-		match = true
-		projectName, err := getProjectNameFromID(sg.TenantID, checker.Projects)
-		if err != nil {
-			err = nil
-		}
-		fmt.Printf("[[rules]]\n")
-		fmt.Printf("tenant = \"%s\"\n", projectName)
-		fmt.Printf("sg = \"%s\"\n", sg.Name)
-		fmt.Printf("created = \"%s\"\n", sg.CreatedAt.Local())
-		fields := []slack.AttachmentField{
-			{Title: "Name", Value: sg.Name},
-			{Title: "Tenant", Value: projectName, Short: true},
-			{Title: "ID", Value: sg.ID, Short: true},
-			{Title: "Created", Value: sg.CreatedAt.Local().String()},
-		}
+	if matched {
+		finding := checker.newFinding(CheckPolicy+":"+policy.Policy, SeverityMedium, sg.TenantID, ResourceSecurityGroup, sg.ID, sg.Name)
+		finding.addDetail("Created", sg.CreatedAt.Local().String())
 		value := ""
 		for _, rule := range sg.Rules {
-			value += fmt.Sprintf("%s, IP Range: %s, Port Range: %s\n", rule.Direction, rule.RemoteIPPrefix, fmt.Sprintf("%d-%d", rule.PortRangeMin, rule.PortRangeMax))
+			value += fmt.Sprintf("%s, Protocol: %s, IP Range: %s, Port Range: %s\n", rule.Direction, normalizeProtocol(rule.Protocol), effectiveSource(rule), formatPortRange(rule))
 		}
-		fields = append(fields, slack.AttachmentField{
-			Title: "Rules",
-			Value: value,
-		})
-		attachment := slack.Attachment{
-			Color:  "#ff6347",
-			Fields: fields,
+		finding.addDetail("Rules", value)
+		if len(instances) > 0 {
+			finding.addDetail("Instances", formatInstances(instances))
 		}
-		checker.Attachments = append(checker.Attachments, attachment)
-		return true, err
+		return &finding, nil
 	}
-	return false, err
+	return nil, nil
 }
 
-func isPrivateIP(ip net.IP) (bool, error) {
-	var privateIPBlocks []*net.IPNet
-
-	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() {
-		return true, nil
+// evalPolicy evaluates the policy with the JSON representation of the document as input.
+func evalPolicy(query rego.PreparedEvalQuery, document interface{}) (bool, error) {
+	var input interface{}
+	jsonData, err := json.Marshal(document)
+	if err != nil {
+		return false, err
 	}
-
-	for _, cidr := range []string{
-		"10.0.0.0/8",
-		"172.16.0.0/12",
-		"192.168.0.0/16",
-	} {
-		_, block, err := net.ParseCIDR(cidr)
-		if err != nil {
-			return true, err
-		}
-
-		privateIPBlocks = append(privateIPBlocks, block)
+	err = json.Unmarshal(jsonData, &input)
+	if err != nil {
+		return false, err
 	}
 
-	for _, block := range privateIPBlocks {
-		if block.Contains(ip) {
-			return true, nil
-		}
+	rs, err := query.Eval(context.Background(), rego.EvalInput(input))
+	if err != nil {
+		return false, err
 	}
-
-	return false, nil
+	return len(rs) > 0 && rs[0].Bindings["x"].(bool), nil
 }
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/layer3/floatingips"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/ports"
+	"github.com/open-policy-agent/opa/rego"
+)
+
+// neutronPort is a Neutron port with the extension attributes used by the checks.
+type neutronPort struct {
+	ports.Port
+	// PortSecurityEnabled is nil when the port security extension is not available.
+	PortSecurityEnabled *bool `json:"port_security_enabled"`
+}
+
+// portAddresses returns fixed IPs and floating IPs bound to the port.
+func portAddresses(port neutronPort, fips []floatingips.FloatingIP) []string {
+	addresses := []string{}
+	for _, ip := range port.FixedIPs {
+		addresses = append(addresses, ip.IPAddress)
+	}
+	for _, fip := range fips {
+		if fip.PortID == port.ID {
+			addresses = append(addresses, fip.FloatingIP)
+		}
+	}
+	return addresses
+}
+
+// findPortSecurityDisabled reports public ports whose port security is disabled.
+// Security groups are not applied to such ports at all.
+func (checker *OpenStackSecurityGroupChecker) findPortSecurityDisabled(ports []neutronPort, fips []floatingips.FloatingIP, allowed_sg []string) ([]Finding, error) {
+	findings := []Finding{}
+	for _, port := range ports {
+		if port.PortSecurityEnabled == nil || *port.PortSecurityEnabled {
+			continue
+		}
+		isPublic, err := checker.isPublicPort(port, fips)
+		if err != nil {
+			return nil, err
+		}
+		if !isPublic {
+			continue
+		}
+		if contain(allowed_sg, port.ID) {
+			continue
+		}
+
+		finding := checker.newFinding(CheckPortSecurityDisabled, SeverityHigh, port.TenantID, ResourcePort, port.ID, port.Name)
+		finding.Ports = []string{port.ID}
+		finding.IPs = portAddresses(port, fips)
+		finding.addDetail("PortSecurity", "disabled")
+		finding.addShortDetail("DeviceOwner", port.DeviceOwner)
+		finding.addShortDetail("Instance", checker.describeInstance(port, fips))
+		findings = append(findings, finding)
+	}
+	return findings, nil
+}
+
+// matchPortPolicy returns a finding if the policy matches the port, or nil.
+func (checker *OpenStackSecurityGroupChecker) matchPortPolicy(query rego.PreparedEvalQuery, policy Policy, port neutronPort, fips []floatingips.FloatingIP) (*Finding, error) {
+	var p struct {
+		neutronPort
+		PublicIPs []string      `json:"public_ips"`
+		Instance  *instanceInfo `json:"instance,omitempty"`
+	}
+	p.neutronPort = port
+	p.PublicIPs = []string{}
+	addresses, err := checker.publicAddresses(port, fips)
+	if err != nil {
+		return nil, err
+	}
+	for _, address := range addresses {
+		p.PublicIPs = append(p.PublicIPs, address.Address)
+	}
+	info, ok, err := checker.instanceOf(port, fips)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		p.Instance = &info
+	}
+
+	matched, err := evalPolicy(query, &p)
+	if err != nil {
+		return nil, err
+	}
+	if !matched {
+		return nil, nil
+	}
+	finding := checker.newFinding(CheckPolicy+":"+policy.Policy, SeverityMedium, port.TenantID, ResourcePort, port.ID, port.Name)
+	finding.Ports = []string{port.ID}
+	finding.IPs = portAddresses(port, fips)
+	portSecurity := "enabled"
+	if port.PortSecurityEnabled != nil && !*port.PortSecurityEnabled {
+		portSecurity = "disabled"
+	}
+	finding.addShortDetail("PortSecurity", portSecurity)
+	finding.addShortDetail("DeviceOwner", port.DeviceOwner)
+	finding.addShortDetail("Instance", checker.describeInstance(port, fips))
+	return &finding, nil
+}
+
+// parseAddressPair parses the ip_address of an allowed address pair, which is either a CIDR or
+// a single address.
+func parseAddressPair(pair ports.AddressPair) (*net.IPNet, error) {
+	if strings.Contains(pair.IPAddress, "/") {
+		_, prefix, err := net.ParseCIDR(pair.IPAddress)
+		return prefix, err
+	}
+	ip := net.ParseIP(pair.IPAddress)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid address pair: %s", pair.IPAddress)
+	}
+	if ip.To4() != nil {
+		return &net.IPNet{IP: ip.To4(), Mask: net.CIDRMask(32, 32)}, nil
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}, nil
+}
+
+// addressPairViolations returns the reasons why the allowed address pairs of the port exceed
+// the configured limits.
+func (checker *OpenStackSecurityGroupChecker) addressPairViolations(port neutronPort) []string {
+	violations := []string{}
+	if checker.Cfg.MaxAddressPairs > 0 && len(port.AllowedAddressPairs) > checker.Cfg.MaxAddressPairs {
+		violations = append(violations, fmt.Sprintf("%d address pairs (max %d)", len(port.AllowedAddressPairs), checker.Cfg.MaxAddressPairs))
+	}
+	for _, pair := range port.AllowedAddressPairs {
+		prefix, err := parseAddressPair(pair)
+		if err != nil {
+			violations = append(violations, err.Error())
+			continue
+		}
+		ones, bits := prefix.Mask.Size()
+		limit := checker.Cfg.MinAddressPairPrefixLength
+		if bits == 128 {
+			limit = checker.Cfg.MinAddressPairPrefixLengthV6
+		}
+		if ones == 0 || ones < limit {
+			violations = append(violations, fmt.Sprintf("%s (mac: %s)", prefix.String(), pair.MACAddress))
+		}
+	}
+	return violations
+}
+
+// findBroadAddressPairs reports ports whose allowed address pairs let the instance send or receive
+// traffic for more addresses than the configured limits.
+func (checker *OpenStackSecurityGroupChecker) findBroadAddressPairs(ports []neutronPort, fips []floatingips.FloatingIP, allowed_sg []string) ([]Finding, error) {
+	findings := []Finding{}
+	for _, port := range ports {
+		if port.PortSecurityEnabled != nil && !*port.PortSecurityEnabled {
+			continue
+		}
+		violations := checker.addressPairViolations(port)
+		if len(violations) == 0 {
+			continue
+		}
+		if contain(allowed_sg, port.ID) {
+			continue
+		}
+
+		finding := checker.newFinding(CheckAddressPairs, SeverityHigh, port.TenantID, ResourcePort, port.ID, port.Name)
+		finding.Ports = []string{port.ID}
+		finding.IPs = portAddresses(port, fips)
+		finding.addDetail("AllowedAddressPairs", strings.Join(violations, "\n"))
+		finding.addShortDetail("DeviceOwner", port.DeviceOwner)
+		finding.addShortDetail("Instance", checker.describeInstance(port, fips))
+		findings = append(findings, finding)
+	}
+	return findings, nil
+}
+
+// describeInstance returns the server attached to the port, or the device ID when it is not a server.
+func (checker *OpenStackSecurityGroupChecker) describeInstance(port neutronPort, fips []floatingips.FloatingIP) string {
+	info, ok, err := checker.instanceOf(port, fips)
+	if err != nil || !ok {
+		return port.DeviceID
+	}
+	return info.String()
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/layer3/floatingips"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/ports"
+	"github.com/open-policy-agent/opa/rego"
+)
+
+func TestMatchPortPolicy(t *testing.T) {
+	query, err := rego.New(
+		rego.Query("x = data.example.allow"),
+		rego.Module("port.rego", `package example
+
+default allow = false
+
+allow {
+	input.port_security_enabled == false
+	count(input.public_ips) > 0
+}
+`),
+	).PrepareForEval(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	disabled, enabled := false, true
+	fips := []floatingips.FloatingIP{{FloatingIP: "203.0.113.10", PortID: "public-disabled"}}
+	tests := []struct {
+		port neutronPort
+		want bool
+	}{
+		{testPort("public-disabled", "10.0.0.1"), true},
+		{testPort("private-disabled", "10.0.0.2"), false},
+		{testPort("public-enabled", "198.51.100.1"), false},
+		{testPort("public-unknown", "198.51.100.2"), false},
+	}
+	tests[0].port.PortSecurityEnabled = &disabled
+	tests[1].port.PortSecurityEnabled = &disabled
+	tests[2].port.PortSecurityEnabled = &enabled
+
+	checker := newTestChecker()
+	for _, tt := range tests {
+		finding, err := checker.matchPortPolicy(query, Policy{Policy: "port.rego", Resource: ResourcePort}, tt.port, fips)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (finding != nil) != tt.want {
+			t.Errorf("matchPortPolicy(%s) = %v, want %v", tt.port.ID, finding, tt.want)
+			continue
+		}
+		if finding != nil && (finding.ResourceType != ResourcePort || finding.ResourceID != tt.port.ID) {
+			t.Errorf("finding of %s is about %s %s", tt.port.ID, finding.ResourceType, finding.ResourceID)
+		}
+	}
+}
+
+func testPairs(addresses ...string) []ports.AddressPair {
+	pairs := []ports.AddressPair{}
+	for _, address := range addresses {
+		pairs = append(pairs, ports.AddressPair{IPAddress: address, MACAddress: "fa:16:3e:00:00:01"})
+	}
+	return pairs
+}
+
+func TestAddressPairViolations(t *testing.T) {
+	tests := []struct {
+		name     string
+		minV4    int
+		minV6    int
+		maxPairs int
+		pairs    []ports.AddressPair
+		want     []string
+	}{
+		{"no pairs", 24, 64, 0, nil, []string{}},
+		{"/0 without limits", 0, 0, 0, testPairs("0.0.0.0/0", "::/0"), []string{"0.0.0.0/0 (mac: fa:16:3e:00:00:01)", "::/0 (mac: fa:16:3e:00:00:01)"}},
+		{"broad prefix without limits", 0, 0, 0, testPairs("10.0.0.0/8"), []string{}},
+		{"single address", 24, 64, 0, testPairs("10.0.0.5"), []string{}},
+		{"at the limit", 24, 64, 0, testPairs("10.0.0.0/24", "2001:db8::/64"), []string{}},
+		{"shorter than the limit", 24, 64, 0, testPairs("10.0.0.0/16", "2001:db8::/48"), []string{"10.0.0.0/16 (mac: fa:16:3e:00:00:01)", "2001:db8::/48 (mac: fa:16:3e:00:00:01)"}},
+		{"IPv6 limit applies to IPv6 only", 0, 64, 0, testPairs("10.0.0.0/16", "2001:db8::/48"), []string{"2001:db8::/48 (mac: fa:16:3e:00:00:01)"}},
+		{"invalid address", 0, 0, 0, testPairs("foo"), []string{"invalid address pair: foo"}},
+		{"within max_address_pairs", 0, 0, 2, testPairs("10.0.0.5", "10.0.0.6"), []string{}},
+		{"over max_address_pairs", 0, 0, 2, testPairs("10.0.0.5", "10.0.0.6", "10.0.0.7"), []string{"3 address pairs (max 2)"}},
+	}
+	for _, tt := range tests {
+		checker := newTestChecker()
+		checker.Cfg.MinAddressPairPrefixLength = tt.minV4
+		checker.Cfg.MinAddressPairPrefixLengthV6 = tt.minV6
+		checker.Cfg.MaxAddressPairs = tt.maxPairs
+		port := testPort("port", "10.0.0.1")
+		port.AllowedAddressPairs = tt.pairs
+		if got := checker.addressPairViolations(port); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: addressPairViolations() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFindBroadAddressPairs(t *testing.T) {
+	disabled := false
+	broad := testPort("broad", "10.0.0.1")
+	broad.AllowedAddressPairs = testPairs("0.0.0.0/0")
+	narrow := testPort("narrow", "10.0.0.2")
+	narrow.AllowedAddressPairs = testPairs("10.0.0.10")
+	// Port security disabled is reported by its own check.
+	insecure := testPort("insecure", "10.0.0.3")
+	insecure.AllowedAddressPairs = testPairs("0.0.0.0/0")
+	insecure.PortSecurityEnabled = &disabled
+	allowed := testPort("allowed", "10.0.0.4")
+	allowed.AllowedAddressPairs = testPairs("0.0.0.0/0")
+
+	checker := newTestChecker()
+	checker.Cfg.MinAddressPairPrefixLength = 24
+	fips := []floatingips.FloatingIP{{FloatingIP: "203.0.113.10", PortID: "broad"}}
+	findings, err := checker.findBroadAddressPairs([]neutronPort{broad, narrow, insecure, allowed}, fips, []string{"allowed"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(findings) != 1 {
+		t.Fatalf("got %d findings, want 1: %+v", len(findings), findings)
+	}
+	f := findings[0]
+	if f.CheckID != CheckAddressPairs || f.ResourceType != ResourcePort || f.ResourceID != "broad" {
+		t.Errorf("finding = %s %s %s, want %s port broad", f.CheckID, f.ResourceType, f.ResourceID, CheckAddressPairs)
+	}
+	if !contain(f.IPs, "203.0.113.10") {
+		t.Errorf("IPs = %v, want the floating IP", f.IPs)
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/security/rules"
+)
+
+const (
+	minPort = 1
+	maxPort = 65535
+)
+
+// portRange is an inclusive range of ports.
+type portRange struct {
+	Min int
+	Max int
+}
+
+type portRanges []portRange
+
+func (r portRange) String() string {
+	if r.Min == r.Max {
+		return strconv.Itoa(r.Min)
+	}
+	return fmt.Sprintf("%d-%d", r.Min, r.Max)
+}
+
+// rulePortRange returns the ports matched by the security group rule.
+// Neutron stores "all ports" as null, which gophercloud decodes as 0, and EC2 uses -1 for any
+// ICMP code.
+func rulePortRange(rule rules.SecGroupRule) portRange {
+	min, max := rule.PortRangeMin, rule.PortRangeMax
+	if max < 0 {
+		max = 0
+	}
+	if min == 0 && max == 0 {
+		return portRange{Min: minPort, Max: maxPort}
+	}
+	if min == 0 {
+		min = minPort
+	}
+	if max == 0 {
+		max = min
+	}
+	return portRange{Min: min, Max: max}
+}
+
+// parsePortRanges parses a comma separated list of ports and ranges such as "22,80,1000-2000".
+// Ranges may be open-ended: "1024-" means 1024-65535 and "-1023" means 1-1023. A list without
+// any port, e.g. "" or ",", is an error rather than no restriction.
+func parsePortRanges(s string) (portRanges, error) {
+	var result portRanges
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if item == "*" || item == "any" {
+			result = append(result, portRange{Min: minPort, Max: maxPort})
+			continue
+		}
+
+		r := portRange{Min: minPort, Max: maxPort}
+		if i := strings.Index(item, "-"); i >= 0 {
+			if from := strings.TrimSpace(item[:i]); from != "" {
+				n, err := parsePort(from)
+				if err != nil {
+					return nil, err
+				}
+				r.Min = n
+			}
+			if to := strings.TrimSpace(item[i+1:]); to != "" {
+				n, err := parsePort(to)
+				if err != nil {
+					return nil, err
+				}
+				r.Max = n
+			}
+		} else {
+			n, err := parsePort(item)
+			if err != nil {
+				return nil, err
+			}
+			r.Min, r.Max = n, n
+		}
+		if r.Min > r.Max {
+			return nil, fmt.Errorf("invalid port range: %s", item)
+		}
+		result = append(result, r)
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("no port in %q", s)
+	}
+	return result.normalize(), nil
+}
+
+func parsePort(s string) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid port: %s", s)
+	}
+	if n < minPort || n > maxPort {
+		return 0, fmt.Errorf("port out of range: %d", n)
+	}
+	return n, nil
+}
+
+// normalize sorts the ranges and merges overlapping or adjacent ones.
+func (rs portRanges) normalize() portRanges {
+	if len(rs) == 0 {
+		return rs
+	}
+	sorted := make(portRanges, len(rs))
+	copy(sorted, rs)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Min < sorted[j].Min })
+
+	result := portRanges{sorted[0]}
+	for _, r := range sorted[1:] {
+		last := &result[len(result)-1]
+		if r.Min <= last.Max+1 {
+			if r.Max > last.Max {
+				last.Max = r.Max
+			}
+			continue
+		}
+		result = append(result, r)
+	}
+	return result
+}
+
+// uncovered returns the parts of r that are not included in rs.
+func (rs portRanges) uncovered(r portRange) portRanges {
+	result := portRanges{}
+	next := r.Min
+	for _, a := range rs.normalize() {
+		if a.Max < next {
+			continue
+		}
+		if a.Min > r.Max {
+			break
+		}
+		if a.Min > next {
+			result = append(result, portRange{Min: next, Max: a.Min - 1})
+		}
+		next = a.Max + 1
+		if next > r.Max {
+			return result
+		}
+	}
+	return append(result, portRange{Min: next, Max: r.Max})
+}
+
+func (rs portRanges) String() string {
+	items := []string{}
+	for _, r := range rs {
+		items = append(items, r.String())
+	}
+	return strings.Join(items, ",")
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/security/rules"
+)
+
+func TestParsePortRanges(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    string
+		wantErr bool
+	}{
+		{s: "22", want: "22"},
+		{s: "22,80,443", want: "22,80,443"},
+		{s: "80, 22", want: "22,80"},
+		{s: "1000-2000", want: "1000-2000"},
+		{s: "1024-", want: "1024-65535"},
+		{s: "-1023", want: "1-1023"},
+		{s: "*", want: "1-65535"},
+		{s: "any", want: "1-65535"},
+		{s: "1000-2000,1500-3000", want: "1000-3000"},
+		{s: "1000-2000,2001-3000", want: "1000-3000"},
+		{s: "1,65535", want: "1,65535"},
+		{s: "1-65535", want: "1-65535"},
+		{s: "0", wantErr: true},
+		{s: "65536", wantErr: true},
+		{s: "2000-1000", wantErr: true},
+		{s: "ssh", wantErr: true},
+		{s: "", wantErr: true},
+		{s: " ", wantErr: true},
+		{s: ",", wantErr: true},
+		{s: "22,", want: "22"},
+	}
+	for _, tt := range tests {
+		got, err := parsePortRanges(tt.s)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parsePortRanges(%q) error = %v, wantErr %v", tt.s, err, tt.wantErr)
+			continue
+		}
+		if err == nil && got.String() != tt.want {
+			t.Errorf("parsePortRanges(%q) = %s, want %s", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestPortRangesUncovered(t *testing.T) {
+	tests := []struct {
+		allowed string
+		r       portRange
+		want    string
+	}{
+		{"22", portRange{22, 22}, ""},
+		{"22", portRange{80, 80}, "80"},
+		{"1000-2000", portRange{1200, 1300}, ""},
+		{"1000-2000", portRange{500, 2500}, "500-999,2001-2500"},
+		{"1000-2000", portRange{1500, 2500}, "2001-2500"},
+		{"1000-1500,1400-2000", portRange{1000, 2000}, ""},
+		{"1000-1100,1200-1300", portRange{1000, 1300}, "1101-1199"},
+		{"*", portRange{minPort, maxPort}, ""},
+		{"1024-", portRange{minPort, maxPort}, "1-1023"},
+		{"-1023", portRange{minPort, maxPort}, "1024-65535"},
+		{"1,65535", portRange{minPort, maxPort}, "2-65534"},
+		{"2-65534", portRange{minPort, maxPort}, "1,65535"},
+	}
+	for _, tt := range tests {
+		allowed, err := parsePortRanges(tt.allowed)
+		if err != nil {
+			t.Fatalf("parsePortRanges(%q): %s", tt.allowed, err)
+		}
+		if got := allowed.uncovered(tt.r).String(); got != tt.want {
+			t.Errorf("%q.uncovered(%s) = %q, want %q", tt.allowed, tt.r, got, tt.want)
+		}
+	}
+	if got := (portRanges{}).uncovered(portRange{minPort, maxPort}).String(); got != "1-65535" {
+		t.Errorf("no ranges uncover %q, want 1-65535", got)
+	}
+}
+
+func TestCoverPortsEmptyEntry(t *testing.T) {
+	rule := rules.SecGroupRule{Direction: "ingress", Protocol: "tcp", PortRangeMin: 1, PortRangeMax: 100}
+	for _, entry := range []string{"", " ", ","} {
+		if allowd, _ := coverPorts([]Rule{{Port: []string{entry}}}, rule); allowd {
+			t.Errorf("port %q allows tcp 1-100", entry)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import "github.com/gophercloud/gophercloud"
+
+// Provider fetches the resources of a cloud. Resources of every cloud are normalized into the
+// Neutron model, so that the checks and the Rego input are the same for all of them.
+type Provider interface {
+	Fetch() (*inventory, error)
+}
+
+func newProvider(conf Config) Provider {
+	switch conf.Provider {
+	case "aws":
+		return &awsProvider{Cfg: conf.AWS}
+	case "gcp":
+		return &gcpProvider{Cfg: conf.GCP}
+	case "azure":
+		return &azureProvider{Cfg: conf.Azure}
+	}
+	return &openStackProvider{
+		AuthOptions: gophercloud.AuthOptions{
+			IdentityEndpoint: conf.OpenStack.AuthURL,
+			Username:         conf.OpenStack.Username,
+			Password:         conf.OpenStack.Password,
+			DomainName:       "Default",
+			TenantName:       conf.OpenStack.ProjectName,
+		},
+		RegionName:    conf.OpenStack.RegionName,
+		CACert:        conf.OpenStack.CACert,
+		Cert:          conf.OpenStack.Cert,
+		Key:           conf.OpenStack.Key,
+		LoadBalancers: conf.LoadBalancerCheck,
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/layer3/floatingips"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/security/groups"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/security/rules"
+)
+
+// exposureHop is a security group reached through one of its ingress rules, with the member
+// ports of the group that are reached.
+type exposureHop struct {
+	GroupID   string
+	GroupName string
+	Rule      rules.SecGroupRule
+	// RemoteGroupName is the name of the group of a port reached by the previous hop that the
+	// rule allows ingress from. It is empty for the first hop, which is open to the world.
+	RemoteGroupName string
+	Ports           []string
+}
+
+// exposurePath is the chain of security groups and ports through which the world can reach a group.
+// The first hop is open to the world and reaches its public ports. Each following hop allows
+// ingress from a group of a port reached by the previous hop and reaches its member ports.
+type exposurePath []exposureHop
+
+func (p exposurePath) String() string {
+	items := []string{"world"}
+	for _, hop := range p {
+		rule := fmt.Sprintf("%s/%s", normalizeProtocol(hop.Rule.Protocol), formatPortRange(hop.Rule))
+		if hop.RemoteGroupName != "" {
+			rule = fmt.Sprintf("%s from %s", rule, hop.RemoteGroupName)
+		}
+		items = append(items, fmt.Sprintf("%s (%s): %s", hop.GroupName, rule, strings.Join(hop.Ports, ",")))
+	}
+	return strings.Join(items, " -> ")
+}
+
+// findExposurePaths walks from the public ports of the groups that are open to the world to the
+// groups that allow ingress from any group of a reached port, and then to the member ports of
+// those groups. It returns the shortest path to every reachable group that has a member port.
+func (checker *OpenStackSecurityGroupChecker) findExposurePaths(securityGroups []groups.SecGroup, ports []neutronPort, fips []floatingips.FloatingIP) (map[string]exposurePath, error) {
+	members := map[string][]string{}
+	publicMembers := map[string][]string{}
+	portGroups := map[string][]string{}
+	for _, port := range ports {
+		isPublic, err := checker.isPublicPort(port, fips)
+		if err != nil {
+			return nil, err
+		}
+		portGroups[port.ID] = port.SecurityGroups
+		for _, sgid := range port.SecurityGroups {
+			members[sgid] = append(members[sgid], port.ID)
+			if isPublic {
+				publicMembers[sgid] = append(publicMembers[sgid], port.ID)
+			}
+		}
+	}
+	names := map[string]string{}
+	for _, sg := range securityGroups {
+		names[sg.ID] = sg.Name
+	}
+
+	paths := map[string]exposurePath{}
+	queue := []string{}
+	for _, sg := range securityGroups {
+		if len(publicMembers[sg.ID]) == 0 {
+			continue
+		}
+		for _, rule := range sg.Rules {
+			if rule.Direction == "ingress" && effectiveSource(rule).IsWorld() {
+				paths[sg.ID] = exposurePath{{GroupID: sg.ID, GroupName: sg.Name, Rule: rule, Ports: publicMembers[sg.ID]}}
+				queue = append(queue, sg.ID)
+				break
+			}
+		}
+	}
+
+	for len(queue) > 0 {
+		from := paths[queue[0]]
+		queue = queue[1:]
+
+		// Traffic from a reached port matches remote group rules of every group of the port.
+		sources := []string{}
+		for _, portID := range from[len(from)-1].Ports {
+			for _, sgid := range portGroups[portID] {
+				if !contain(sources, sgid) {
+					sources = append(sources, sgid)
+				}
+			}
+		}
+
+		for _, sg := range securityGroups {
+			if _, ok := paths[sg.ID]; ok || len(members[sg.ID]) == 0 {
+				continue
+			}
+			for _, rule := range sg.Rules {
+				if rule.Direction == "ingress" && contain(sources, rule.RemoteGroupID) {
+					remote := names[rule.RemoteGroupID]
+					if remote == "" {
+						remote = rule.RemoteGroupID
+					}
+					path := append(exposurePath{}, from...)
+					paths[sg.ID] = append(path, exposureHop{GroupID: sg.ID, GroupName: sg.Name, Rule: rule, RemoteGroupName: remote, Ports: members[sg.ID]})
+					queue = append(queue, sg.ID)
+					break
+				}
+			}
+		}
+	}
+	return paths, nil
+}
+
+// findTransitiveExposure reports security groups that are not open to the world themselves but
+// can be reached from the world through remote group references.
+func (checker *OpenStackSecurityGroupChecker) findTransitiveExposure(securityGroups []groups.SecGroup, ports []neutronPort, fips []floatingips.FloatingIP, allowed_sg []string) ([]Finding, error) {
+	paths, err := checker.findExposurePaths(securityGroups, ports, fips)
+	if err != nil {
+		return nil, err
+	}
+
+	findings := []Finding{}
+	for _, sg := range securityGroups {
+		path, ok := paths[sg.ID]
+		if !ok || len(path) < 2 {
+			continue
+		}
+		if contain(allowed_sg, sg.ID) {
+			continue
+		}
+
+		finding := checker.newFinding(CheckTransitiveExposure, SeverityMedium, sg.TenantID, ResourceSecurityGroup, sg.ID, sg.Name)
+		finding.Ports = path[len(path)-1].Ports
+		finding.addDetail("Chain", path.String())
+		finding.addDetail("Ports", strings.Join(finding.Ports, "\n"))
+		findings = append(findings, finding)
+	}
+	return findings, nil
+}
+
+func memberPortIDs(sg groups.SecGroup, ports []neutronPort) []string {
+	ids := []string{}
+	for _, port := range ports {
+		if contain(port.SecurityGroups, sg.ID) {
+			ids = append(ids, port.ID)
+		}
+	}
+	return ids
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/layer3/floatingips"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/security/groups"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/security/rules"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/ports"
+)
+
+func newTestChecker() *OpenStackSecurityGroupChecker {
+	return &OpenStackSecurityGroupChecker{Cfg: Config{PrivateRanges: defaultPrivateRanges}}
+}
+
+func testPort(id string, ip string, sgids ...string) neutronPort {
+	return neutronPort{Port: ports.Port{ID: id, FixedIPs: []ports.IP{{IPAddress: ip}}, SecurityGroups: sgids}}
+}
+
+func TestFindExposurePaths(t *testing.T) {
+	securityGroups := []groups.SecGroup{
+		{ID: "web", Name: "web", Rules: []rules.SecGroupRule{
+			{Direction: "ingress", EtherType: "IPv4", Protocol: "tcp", PortRangeMin: 443, PortRangeMax: 443, RemoteIPPrefix: "0.0.0.0/0"},
+		}},
+		{ID: "mgmt", Name: "mgmt"},
+		{ID: "db", Name: "db", Rules: []rules.SecGroupRule{
+			{Direction: "ingress", EtherType: "IPv4", Protocol: "tcp", PortRangeMin: 5432, PortRangeMax: 5432, RemoteGroupID: "mgmt"},
+		}},
+		{ID: "cache", Name: "cache", Rules: []rules.SecGroupRule{
+			{Direction: "ingress", EtherType: "IPv4", Protocol: "tcp", PortRangeMin: 6379, PortRangeMax: 6379, RemoteGroupID: "db"},
+		}},
+		{ID: "backup", Name: "backup", Rules: []rules.SecGroupRule{
+			{Direction: "ingress", EtherType: "IPv4", Protocol: "tcp", PortRangeMin: 22, PortRangeMax: 22, RemoteGroupID: "admin"},
+		}},
+		{ID: "admin", Name: "admin"},
+		// Open to the world, but without public ports.
+		{ID: "internal", Name: "internal", Rules: []rules.SecGroupRule{
+			{Direction: "ingress", EtherType: "IPv4", Protocol: "tcp", RemoteIPPrefix: "0.0.0.0/0"},
+		}},
+	}
+	ps := []neutronPort{
+		// port-a is reachable from the world through web and shares mgmt, which db trusts.
+		testPort("port-a", "10.0.0.1", "web", "mgmt"),
+		testPort("port-b", "10.0.0.2", "db"),
+		testPort("port-c", "10.0.0.3", "cache"),
+		testPort("port-d", "10.0.0.4", "backup"),
+		testPort("port-e", "10.0.0.5", "admin", "internal"),
+	}
+	fips := []floatingips.FloatingIP{{FloatingIP: "203.0.113.10", PortID: "port-a"}}
+
+	paths, err := newTestChecker().findExposurePaths(securityGroups, ps, fips)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		group string
+		chain string
+		ports []string
+	}{
+		{"web", "world -> web (tcp/443-443): port-a", []string{"port-a"}},
+		{"db", "world -> web (tcp/443-443): port-a -> db (tcp/5432-5432 from mgmt): port-b", []string{"port-b"}},
+		{"cache", "world -> web (tcp/443-443): port-a -> db (tcp/5432-5432 from mgmt): port-b -> cache (tcp/6379-6379 from db): port-c", []string{"port-c"}},
+	}
+	for _, tt := range tests {
+		path, ok := paths[tt.group]
+		if !ok {
+			t.Errorf("%s is not reachable", tt.group)
+			continue
+		}
+		if path.String() != tt.chain {
+			t.Errorf("chain of %s = %q, want %q", tt.group, path.String(), tt.chain)
+		}
+		if got := path[len(path)-1].Ports; !reflect.DeepEqual(got, tt.ports) {
+			t.Errorf("ports of %s = %v, want %v", tt.group, got, tt.ports)
+		}
+	}
+	for _, group := range []string{"mgmt", "backup", "admin", "internal"} {
+		if path, ok := paths[group]; ok {
+			t.Errorf("%s is reachable: %s", group, path)
+		}
+	}
+
+	findings, err := newTestChecker().findTransitiveExposure(securityGroups, ps, fips, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := []string{}
+	for _, f := range findings {
+		ids = append(ids, f.ResourceID)
+	}
+	if want := []string{"db", "cache"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("findings = %v, want %v", ids, want)
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/security/rules"
+)
+
+// ruleSource is the effective source of an ingress rule (or destination of an egress rule).
+type ruleSource struct {
+	Prefix        string
+	RemoteGroupID string
+	// Implicit is true when neither remote_ip_prefix nor remote_group_id is set,
+	// which Neutron treats as "any address of the rule's ethertype".
+	Implicit bool
+}
+
+func effectiveSource(rule rules.SecGroupRule) ruleSource {
+	if rule.RemoteIPPrefix != "" {
+		return ruleSource{Prefix: rule.RemoteIPPrefix}
+	}
+	if rule.RemoteGroupID != "" {
+		return ruleSource{RemoteGroupID: rule.RemoteGroupID}
+	}
+	if rule.EtherType == "IPv6" {
+		return ruleSource{Prefix: "::/0", Implicit: true}
+	}
+	return ruleSource{Prefix: "0.0.0.0/0", Implicit: true}
+}
+
+func (s ruleSource) IsWorld() bool {
+	return s.RemoteGroupID == "" && isWorldPrefix(s.Prefix)
+}
+
+func (s ruleSource) String() string {
+	if s.RemoteGroupID != "" {
+		return fmt.Sprintf("group:%s", s.RemoteGroupID)
+	}
+	if s.Implicit {
+		return fmt.Sprintf("%s (implicit: no remote prefix or group)", s.Prefix)
+	}
+	return s.Prefix
+}
+
+var protocolNames = map[string]string{
+	"1":   "icmp",
+	"6":   "tcp",
+	"17":  "udp",
+	"47":  "gre",
+	"50":  "esp",
+	"51":  "ah",
+	"58":  "icmpv6",
+	"132": "sctp",
+}
+
+// normalizeProtocol converts a Neutron protocol value (name or number) to a canonical name.
+// An empty protocol matches every protocol and is returned as "any".
+func normalizeProtocol(protocol string) string {
+	p := strings.ToLower(strings.TrimSpace(protocol))
+	if name, ok := protocolNames[p]; ok {
+		return name
+	}
+	switch p {
+	case "", "any":
+		return "any"
+	case "ipv6-icmp", "icmp6":
+		return "icmpv6"
+	}
+	return p
+}
+
+// protocolHasPorts returns true if port_range_min/max of the protocol are port numbers.
+func protocolHasPorts(protocol string) bool {
+	switch protocol {
+	case "tcp", "udp", "sctp", "udplite", "dccp":
+		return true
+	}
+	return false
+}
+
+// knownProtocols are the protocol names accepted by Neutron, in addition to protocol numbers.
+var knownProtocols = []string{
+	"any", "ah", "dccp", "egp", "esp", "gre", "hopopt", "icmp", "icmpv6", "igmp", "ipip",
+	"ipv6-encap", "ipv6-frag", "ipv6-nonxt", "ipv6-opts", "ipv6-route", "ospf", "pgm", "rsvp",
+	"sctp", "tcp", "udp", "udplite", "vrrp",
+}
+
+// isKnownProtocol returns true if the normalized protocol is a protocol name or number.
+func isKnownProtocol(protocol string) bool {
+	if n, err := strconv.Atoi(protocol); err == nil {
+		return n >= 0 && n <= 255
+	}
+	return contain(knownProtocols, protocol)
+}
+
+// parseAllowedPort splits an allowed port entry such as "udp/53" into protocol and port range.
+// Entries without a protocol that are ports or ranges (e.g. "22", "1000-2000", "*") are tcp ports,
+// other entries (e.g. "icmp", "any") allow every port of the protocol. An empty entry has no
+// protocol and allows nothing.
+func parseAllowedPort(entry string) (string, string) {
+	entry = strings.TrimSpace(entry)
+	if entry == "" {
+		return "", ""
+	}
+	if i := strings.Index(entry, "/"); i >= 0 {
+		return normalizeProtocol(entry[:i]), strings.TrimSpace(entry[i+1:])
+	}
+	if entry != "any" {
+		if _, err := parsePortRanges(entry); err == nil {
+			return "tcp", entry
+		}
+	}
+	return normalizeProtocol(entry), ""
+}
+
+func formatPortRange(rule rules.SecGroupRule) string {
+	protocol := normalizeProtocol(rule.Protocol)
+	switch {
+	case protocolHasPorts(protocol):
+		if rule.PortRangeMin == 0 && rule.PortRangeMax == 0 {
+			return "any"
+		}
+		return fmt.Sprintf("%d-%d", rule.PortRangeMin, rule.PortRangeMax)
+	case protocol == "icmp" || protocol == "icmpv6":
+		// Null types and codes of Neutron are decoded as 0, and any code of EC2 is -1.
+		if rule.PortRangeMin == 0 && rule.PortRangeMax == 0 {
+			return "any"
+		}
+		if rule.PortRangeMax < 0 {
+			return fmt.Sprintf("type %d", rule.PortRangeMin)
+		}
+		return fmt.Sprintf("type %d code %d", rule.PortRangeMin, rule.PortRangeMax)
+	}
+	return "any"
+}
@@ -0,0 +1,58 @@
+package main
+
+import "testing"
+
+func TestParseAllowedPort(t *testing.T) {
+	tests := []struct {
+		entry    string
+		protocol string
+		ports    string
+	}{
+		{"22", "tcp", "22"},
+		{"1000-2000", "tcp", "1000-2000"},
+		{"1024-", "tcp", "1024-"},
+		{"22,80", "tcp", "22,80"},
+		{"*", "tcp", "*"},
+		{"udp/53", "udp", "53"},
+		{"17/53", "udp", "53"},
+		{"icmp", "icmp", ""},
+		{"any", "any", ""},
+		{"gre", "gre", ""},
+		{"foo", "foo", ""},
+		{"", "", ""},
+		{" ", "", ""},
+		{",", ",", ""},
+	}
+	for _, tt := range tests {
+		protocol, ports := parseAllowedPort(tt.entry)
+		if protocol != tt.protocol || ports != tt.ports {
+			t.Errorf("parseAllowedPort(%q) = %q, %q, want %q, %q", tt.entry, protocol, ports, tt.protocol, tt.ports)
+		}
+	}
+}
+
+func TestValidateRulesProtocol(t *testing.T) {
+	tests := []struct {
+		port    string
+		wantErr bool
+	}{
+		{"*", false},
+		{"22", false},
+		{"udp/53", false},
+		{"112/", false},
+		{"icmp", false},
+		{"foo", true},
+		{"foo/22", true},
+		{"300/", true},
+		{"", true},
+		{" ", true},
+		{",", true},
+		{"tcp/,", true},
+	}
+	for _, tt := range tests {
+		err := validateRules([]Rule{{Tenant: "web", SG: "web", Port: []string{tt.port}}}, false)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("validateRules(port %q) error = %v, wantErr %v", tt.port, err, tt.wantErr)
+		}
+	}
+}
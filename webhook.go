@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/slack-go/slack"
+)
+
+const (
+	WEBHOOK_TIMESTAMP_HEADER = "X-SG-Inspector-Timestamp"
+	WEBHOOK_SIGNATURE_HEADER = "X-SG-Inspector-Signature"
+)
+
+var webhookClient = &http.Client{Timeout: 30 * time.Second}
+
+// postJSON posts the payload as JSON with the headers and fails on a non-2xx response.
+func postJSON(url string, payload interface{}, headers map[string]string) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return postBody(url, body, headers)
+}
+
+func postBody(url string, body []byte, headers map[string]string) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		b, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("%s returned %s: %s", url, resp.Status, strings.TrimSpace(string(b)))
+	}
+	return nil
+}
+
+// webhookNotifier posts the notification as JSON. When a secret is set, the request has the unix
+// time in X-SG-Inspector-Timestamp and "sha256=" followed by the hex encoded HMAC-SHA256 of
+// "<timestamp>.<body>" in X-SG-Inspector-Signature.
+type webhookNotifier struct {
+	URL    string
+	Secret string
+}
+
+func (w *webhookNotifier) Notify(n Notification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	headers := map[string]string{}
+	if w.Secret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		headers[WEBHOOK_TIMESTAMP_HEADER] = timestamp
+		headers[WEBHOOK_SIGNATURE_HEADER] = "sha256=" + signWebhook(w.Secret, timestamp, body)
+	}
+	if err := postBody(w.URL, body, headers); err != nil {
+		return errors.Wrapf(err, "Failed to post webhook")
+	}
+	return nil
+}
+
+func signWebhook(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// mattermostPayload is a message of Mattermost incoming webhooks, which accept Slack attachments.
+type mattermostPayload struct {
+	Channel     string             `json:"channel,omitempty"`
+	Username    string             `json:"username,omitempty"`
+	IconEmoji   string             `json:"icon_emoji,omitempty"`
+	Text        string             `json:"text,omitempty"`
+	Attachments []slack.Attachment `json:"attachments,omitempty"`
+}
+
+// mattermostNotifier posts the prefix with the findings as attachments, followed by the suffix.
+type mattermostNotifier struct {
+	URL       string
+	Channel   string
+	Username  string
+	IconEmoji string
+}
+
+func (m *mattermostNotifier) Notify(n Notification) error {
+	payload := mattermostPayload{
+		Channel:     m.Channel,
+		Username:    m.Username,
+		IconEmoji:   m.IconEmoji,
+		Text:        n.Prefix,
+		Attachments: n.attachments(),
+	}
+	if err := postJSON(m.URL, payload, nil); err != nil {
+		return errors.Wrapf(err, "Failed to post to Mattermost")
+	}
+	if n.Suffix == "" {
+		return nil
+	}
+	payload.Text, payload.Attachments = n.Suffix, nil
+	if err := postJSON(m.URL, payload, nil); err != nil {
+		return errors.Wrapf(err, "Failed to post suffix message to Mattermost")
+	}
+	return nil
+}
+
+// teamsMessageCard is a message of Microsoft Teams incoming webhook connectors.
+type teamsMessageCard struct {
+	Type       string         `json:"@type"`
+	Context    string         `json:"@context"`
+	Summary    string         `json:"summary"`
+	ThemeColor string         `json:"themeColor,omitempty"`
+	Text       string         `json:"text,omitempty"`
+	Sections   []teamsSection `json:"sections,omitempty"`
+}
+
+type teamsSection struct {
+	ActivityTitle string      `json:"activityTitle,omitempty"`
+	Text          string      `json:"text,omitempty"`
+	Facts         []teamsFact `json:"facts,omitempty"`
+}
+
+type teamsFact struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// teamsNotifier posts the notification as a message card with a section per finding.
+type teamsNotifier struct {
+	URL string
+}
+
+func (t *teamsNotifier) Notify(n Notification) error {
+	card := teamsMessageCard{
+		Type:     "MessageCard",
+		Context:  "https://schema.org/extensions",
+		Summary:  n.Prefix,
+		Text:     n.Prefix,
+		Sections: []teamsSection{},
+	}
+	severity := SeverityLow
+	for _, f := range n.Findings {
+		if f.Severity == SeverityHigh || (f.Severity == SeverityMedium && severity == SeverityLow) {
+			severity = f.Severity
+		}
+		section := teamsSection{ActivityTitle: fmt.Sprintf("%s: %s", f.CheckID, f.ResourceName)}
+		for _, field := range f.Attachment().Fields {
+			section.Facts = append(section.Facts, teamsFact{Name: field.Title, Value: strings.Replace(field.Value, "\n", "<br>", -1)})
+		}
+		card.Sections = append(card.Sections, section)
+	}
+	for _, s := range n.Sections {
+		card.Sections = append(card.Sections, teamsSection{ActivityTitle: s.Title, Text: strings.Join(s.Items, "<br>")})
+	}
+	if n.Suffix != "" {
+		card.Sections = append(card.Sections, teamsSection{Text: n.Suffix})
+	}
+	card.ThemeColor = strings.TrimPrefix(severity.color(), "#")
+
+	if err := postJSON(t.URL, card, nil); err != nil {
+		return errors.Wrapf(err, "Failed to post to Teams")
+	}
+	return nil
+}
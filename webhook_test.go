@@ -0,0 +1,84 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// verifyWebhook verifies a request the way receivers are documented to: the signature must be
+// the HMAC-SHA256 of "<timestamp>.<body>" and the timestamp recent.
+func verifyWebhook(secret string, r *http.Request, body []byte) bool {
+	timestamp := r.Header.Get("X-SG-Inspector-Timestamp")
+	sec, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || time.Since(time.Unix(sec, 0)) > 5*time.Minute {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "." + string(body)))
+	expected := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	return hmac.Equal([]byte(expected), []byte(r.Header.Get("X-SG-Inspector-Signature")))
+}
+
+func TestWebhookNotifierSignature(t *testing.T) {
+	tests := []struct {
+		name         string
+		secret       string
+		verifyWith   string
+		wantVerified bool
+	}{
+		{"signed", "s3cret", "s3cret", true},
+		{"other secret", "s3cret", "other", false},
+		{"unsigned", "", "s3cret", false},
+	}
+	for _, tt := range tests {
+		var received Notification
+		verified := false
+		headers := http.Header{}
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, err := ioutil.ReadAll(r.Body)
+			if err != nil {
+				t.Errorf("%s: %s", tt.name, err)
+				return
+			}
+			headers = r.Header
+			verified = verifyWebhook(tt.verifyWith, r, body)
+			if err := json.Unmarshal(body, &received); err != nil {
+				t.Errorf("%s: invalid body: %s", tt.name, err)
+			}
+		}))
+
+		n := Notification{Prefix: "prefix", Suffix: "suffix", Findings: []Finding{{CheckID: CheckWorldOpen, ResourceID: "sg-1"}}}
+		err := (&webhookNotifier{URL: server.URL, Secret: tt.secret}).Notify(n)
+		server.Close()
+		if err != nil {
+			t.Fatalf("%s: %s", tt.name, err)
+		}
+		if verified != tt.wantVerified {
+			t.Errorf("%s: verified = %v, want %v", tt.name, verified, tt.wantVerified)
+		}
+		if tt.secret == "" && (headers.Get(WEBHOOK_TIMESTAMP_HEADER) != "" || headers.Get(WEBHOOK_SIGNATURE_HEADER) != "") {
+			t.Errorf("%s: unsigned request has signature headers", tt.name)
+		}
+		if received.Prefix != "prefix" || len(received.Findings) != 1 || received.Findings[0].ResourceID != "sg-1" {
+			t.Errorf("%s: received %+v", tt.name, received)
+		}
+	}
+}
+
+func TestWebhookNotifierError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+	}))
+	defer server.Close()
+	if err := (&webhookNotifier{URL: server.URL, Secret: "s3cret"}).Notify(Notification{}); err == nil {
+		t.Error("a 401 response is not an error")
+	}
+}